@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -43,6 +44,7 @@ func main() {
 
 	// 2. Initialize RBAC service with 1-minute auto-refresh
 	rbacService := rbac.NewRBACService(privRepo, 1*time.Minute, rbac.NewConsoleLogger())
+	defer rbacService.Close()
 
 	// 3. Setup Echo
 	e := echo.New()
@@ -84,30 +86,32 @@ func initDB() *gorm.DB {
 		panic(err)
 	}
 
-	db.Exec(`
-	CREATE TABLE IF NOT EXISTS privileges (
-		id TEXT PRIMARY KEY,
-		code TEXT NOT NULL
-	);
-	`)
-
-	db.Exec(`
-	CREATE TABLE IF NOT EXISTS role_privileges (
-		id TEXT PRIMARY KEY,
-		role_id TEXT NOT NULL,
-		privilege_id TEXT NOT NULL
-	);
-	`)
+	if err := rbacgorm.Migrate(context.Background(), db); err != nil {
+		panic(err)
+	}
 
 	return db
 }
 
 func seedData(db *gorm.DB) {
+	ctx := context.Background()
+	manager := rbacgorm.NewGormRoleManager(db, nil)
+
 	// Seed privilege
-	db.Exec(`INSERT INTO privileges (id, code) VALUES ('p1', 'read:compliance')`)
+	if err := manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "read:compliance"}); err != nil {
+		panic(err)
+	}
 
 	// Link 'admin' role to the privilege
-	db.Exec(`INSERT INTO role_privileges (id, role_id, privilege_id) VALUES ('rp1', 'admin', 'p1')`)
+	if err := manager.CreateRole(ctx, rbac.Role{ID: "admin", Name: "Admin"}); err != nil {
+		panic(err)
+	}
+	if err := manager.GrantPrivileges(ctx, "admin", "read:compliance"); err != nil {
+		panic(err)
+	}
 
 	// 'guest' role has no privileges
+	if err := manager.CreateRole(ctx, rbac.Role{ID: "guest", Name: "Guest"}); err != nil {
+		panic(err)
+	}
 }
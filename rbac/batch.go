@@ -0,0 +1,152 @@
+package rbac
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultRefreshBatchSize is the number of roles fetched per call to a
+// BatchPrivilegeRepository unless WithRefreshBatchSize says otherwise
+const DefaultRefreshBatchSize = 500
+
+// refreshBatches splits roleIDs into the units refreshed together: batches for a
+// BatchPrivilegeRepository outside tenants, single roles otherwise
+func (s *rbacService) refreshBatches(ctx context.Context, roleIDs []string) [][]string {
+	size := 1
+	if _, ok := s.repo.(BatchPrivilegeRepository); ok {
+		if tenantID, _ := GetTenantIDFromContext(ctx); tenantID == "" {
+			size = s.refreshBatchSize
+			if size <= 0 {
+				size = DefaultRefreshBatchSize
+			}
+		}
+	}
+
+	return chunk(roleIDs, size)
+}
+
+// chunk splits roleIDs into consecutive slices of at most size roles
+func chunk(roleIDs []string, size int) [][]string {
+	chunks := make([][]string, 0, (len(roleIDs)+size-1)/size)
+	for start := 0; start < len(roleIDs); start += size {
+		chunks = append(chunks, roleIDs[start:min(start+size, len(roleIDs))])
+	}
+	return chunks
+}
+
+// refreshBatch reloads a batch of roles, fetching their privileges in one call when
+// the repository supports it. A failed batch leaves the cached entries in place.
+func (s *rbacService) refreshBatch(ctx context.Context, roleIDs []string) {
+	batchRepo, ok := s.repo.(BatchPrivilegeRepository)
+	if !ok || len(roleIDs) == 1 {
+		for _, roleID := range roleIDs {
+			s.refreshRole(ctx, roleID, s.fetchOwnPrivileges)
+		}
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	fetched, err := batchRepo.FetchPrivilegesByRoleIDs(ctx, roleIDs)
+	if err != nil {
+		s.stats.loadErrors.Add(1)
+		s.logger.Errorf("Error refreshing privileges for %d roles: %v", len(roleIDs), err)
+		return
+	}
+
+	s.refreshFetched(ctx, roleIDs, fetched, func(ctx context.Context, roleID string) (map[string]bool, time.Time, error) {
+		return nil, time.Time{}, &RoleNotFoundError{RoleID: roleID}
+	})
+}
+
+// streamsRefresh reports whether a refresh of roleIDs reads every role in one pass
+// over a StreamingPrivilegeRepository. The pass reads all roles, cached or not, so by
+// default it only replaces one query per role, never batches; WithRefreshStreamingThreshold
+// changes that. Tenant caches are never streamed.
+func (s *rbacService) streamsRefresh(ctx context.Context, roleIDs []string) bool {
+	if _, ok := s.repo.(StreamingPrivilegeRepository); !ok {
+		return false
+	}
+	if tenantID, _ := GetTenantIDFromContext(ctx); tenantID != "" {
+		return false
+	}
+
+	switch {
+	case s.refreshStreamThreshold > 0:
+		return len(roleIDs) >= s.refreshStreamThreshold
+	case s.refreshStreamThreshold < 0:
+		return false
+	}
+	_, batched := s.repo.(BatchPrivilegeRepository)
+	return !batched && len(roleIDs) > 1
+}
+
+// refreshAll reloads roleIDs from one pass over a StreamingPrivilegeRepository.
+// A failed pass leaves the cached entries in place.
+func (s *rbacService) refreshAll(ctx context.Context, roleIDs []string) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Only the requested roles are kept while streaming
+	requested := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		requested[roleID] = true
+	}
+	fetched := make(map[string]map[string]bool, len(roleIDs))
+	err := s.repo.(StreamingPrivilegeRepository).FetchAll(ctx, func(roleID string, privileges map[string]bool) error {
+		if requested[roleID] {
+			fetched[roleID] = privileges
+		}
+		return nil
+	})
+	if err != nil {
+		s.stats.loadErrors.Add(1)
+		s.logger.Errorf("Error refreshing privileges of all roles: %v", err)
+		return
+	}
+
+	// A role without privileges may still exist, so it is asked for on its own. That,
+	// and extra grants, may still query the repository, so roles go one at a time.
+	s.refreshConcurrently(ctx, chunk(roleIDs, 1), func(ctx context.Context, roleIDs []string) {
+		s.refreshFetched(ctx, roleIDs, fetched, s.fetchOwnPrivileges)
+	})
+}
+
+// refreshFetched reloads roleIDs from privileges fetched in bulk. missing loads a
+// requested role absent from fetched; ancestors outside roleIDs are fetched on their own.
+func (s *rbacService) refreshFetched(ctx context.Context, roleIDs []string, fetched map[string]map[string]bool, missing ownPrivilegesFunc) {
+	requested := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		requested[roleID] = true
+	}
+
+	fetch := func(ctx context.Context, roleID string) (map[string]bool, time.Time, error) {
+		if privileges, ok := fetched[roleID]; ok {
+			if privileges == nil {
+				privileges = map[string]bool{}
+			}
+			return s.withExtraGrants(ctx, roleID, privileges)
+		}
+		if requested[roleID] {
+			return missing(ctx, roleID)
+		}
+		// An ancestor outside the batch
+		return s.fetchOwnPrivileges(ctx, roleID)
+	}
+
+	for _, roleID := range roleIDs {
+		s.refreshRole(ctx, roleID, fetch)
+	}
+}
+
+// nextRefreshDelay returns the wait before the next periodic refresh: the interval
+// plus a random share of the jitter, so a fleet of services drifts apart
+func (s *rbacService) nextRefreshDelay(interval time.Duration) time.Duration {
+	if s.refreshJitter <= 0 {
+		return interval
+	}
+	return interval + rand.N(s.refreshJitter)
+}
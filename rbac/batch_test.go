@@ -0,0 +1,295 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockBatchPrivilegeRepository struct {
+	mu          sync.Mutex
+	privileges  map[string]map[string]bool
+	err         error
+	batches     [][]string
+	singleCalls int
+}
+
+func (m *mockBatchPrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.singleCalls++
+	privileges, ok := m.privileges[roleID]
+	if !ok {
+		return nil, &RoleNotFoundError{RoleID: roleID}
+	}
+	return privileges, nil
+}
+
+func (m *mockBatchPrivilegeRepository) FetchPrivilegesByRoleIDs(ctx context.Context, roleIDs []string) (map[string]map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.batches = append(m.batches, append([]string(nil), roleIDs...))
+	if m.err != nil {
+		return nil, m.err
+	}
+	result := make(map[string]map[string]bool)
+	for _, roleID := range roleIDs {
+		if privileges, ok := m.privileges[roleID]; ok {
+			result[roleID] = privileges
+		}
+	}
+	return result, nil
+}
+
+func TestRBACService_RefreshCache_Batched(t *testing.T) {
+	repo := &mockBatchPrivilegeRepository{privileges: map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+		"admin":  {"delete:users": true},
+	}}
+	svc := NewRBACService(repo, 0, nil, WithRefreshBatchSize(2), WithRefreshConcurrency(2)).(*rbacService)
+	ctx := context.Background()
+
+	for _, roleID := range []string{"viewer", "editor", "admin"} {
+		if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	repo.mu.Lock()
+	repo.singleCalls = 0
+	repo.privileges["editor"] = map[string]bool{"write:posts": true}
+	delete(repo.privileges, "admin")
+	repo.mu.Unlock()
+
+	roleIDs := svc.localCache().GetAllKeys()
+	sort.Strings(roleIDs)
+	svc.refreshCache(ctx, roleIDs)
+
+	// The last role is left alone in its batch and fetched on its own
+	if len(repo.batches) != 1 || len(repo.batches[0]) != 2 || repo.singleCalls != 1 {
+		t.Errorf("expected 1 batch call of 2 roles and 1 single call, got %v and %d", repo.batches, repo.singleCalls)
+	}
+	if got, _ := svc.localCache().Get("editor"); !got["write:posts"] {
+		t.Errorf("expected editor to be refreshed, got %v", got)
+	}
+	if _, ok := svc.localCache().Get("admin"); ok {
+		t.Error("expected a role missing from the batch to be dropped")
+	}
+	if got, _ := svc.localCache().Get("viewer"); !got["read:users"] {
+		t.Errorf("expected viewer to be kept, got %v", got)
+	}
+}
+
+func TestRBACService_RefreshCache_BatchError(t *testing.T) {
+	repo := &mockBatchPrivilegeRepository{privileges: map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+	}}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+	ctx := context.Background()
+
+	for _, roleID := range []string{"viewer", "editor"} {
+		if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	repo.err = errors.New("db down")
+	svc.refreshCache(ctx, []string{"viewer", "editor"})
+
+	if svc.localCache().Len() != 2 {
+		t.Errorf("expected cached roles to survive a failed batch, got %d", svc.localCache().Len())
+	}
+	if got := svc.Stats().LoadErrors; got != 1 {
+		t.Errorf("expected 1 load error, got %d", got)
+	}
+}
+
+// mockStreamingPrivilegeRepository also reads every role in one pass
+type mockStreamingPrivilegeRepository struct {
+	mockBatchPrivilegeRepository
+	passes int
+}
+
+func (m *mockStreamingPrivilegeRepository) FetchAll(ctx context.Context, fn func(roleID string, privileges map[string]bool) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.passes++
+	if m.err != nil {
+		return m.err
+	}
+	roleIDs := make([]string, 0, len(m.privileges))
+	for roleID, privileges := range m.privileges {
+		if len(privileges) > 0 {
+			roleIDs = append(roleIDs, roleID)
+		}
+	}
+	sort.Strings(roleIDs)
+	for _, roleID := range roleIDs {
+		if err := fn(roleID, m.privileges[roleID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRBACService_RefreshCache_Streamed(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   int
+		err         error
+		wantPasses  int
+		wantBatches int
+		wantEditor  string
+		wantAdmin   bool
+	}{
+		{name: "batches by default", wantBatches: 2, wantEditor: "write:posts"},
+		{name: "one pass at the threshold", threshold: 4, wantPasses: 1, wantEditor: "write:posts"},
+		{name: "batches below the threshold", threshold: 5, wantBatches: 2, wantEditor: "write:posts"},
+		{name: "failed pass keeps the cache", threshold: 4, err: errors.New("db down"), wantPasses: 1, wantEditor: "write:users", wantAdmin: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockStreamingPrivilegeRepository{mockBatchPrivilegeRepository: mockBatchPrivilegeRepository{privileges: map[string]map[string]bool{
+				"viewer": {"read:users": true},
+				"editor": {"write:users": true},
+				"admin":  {"delete:users": true},
+				"guest":  {},
+				"other":  {"read:other": true},
+			}}}
+			svc := NewRBACService(repo, 0, nil, WithRefreshBatchSize(2), WithRefreshStreamingThreshold(tt.threshold)).(*rbacService)
+			ctx := context.Background()
+
+			roleIDs := []string{"admin", "editor", "guest", "viewer"}
+			for _, roleID := range roleIDs {
+				if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			repo.mu.Lock()
+			repo.singleCalls = 0
+			repo.err = tt.err
+			repo.privileges["editor"] = map[string]bool{"write:posts": true}
+			delete(repo.privileges, "admin")
+			repo.mu.Unlock()
+
+			svc.refreshCache(ctx, roleIDs)
+
+			if repo.passes != tt.wantPasses || len(repo.batches) != tt.wantBatches {
+				t.Errorf("expected %d passes and %d batches, got %d and %v", tt.wantPasses, tt.wantBatches, repo.passes, repo.batches)
+			}
+			if got, _ := svc.localCache().Get("editor"); !got[tt.wantEditor] {
+				t.Errorf("expected editor to hold %s, got %v", tt.wantEditor, got)
+			}
+			if _, ok := svc.localCache().Get("admin"); ok != tt.wantAdmin {
+				t.Errorf("expected admin cached = %v", tt.wantAdmin)
+			}
+			if got, ok := svc.localCache().Get("guest"); !ok || len(got) != 0 {
+				t.Errorf("expected guest to stay cached without privileges, got %v, %v", got, ok)
+			}
+			if _, ok := svc.localCache().Get("other"); ok {
+				t.Error("expected roles outside the refresh to stay uncached")
+			}
+			if tt.wantPasses > 0 && tt.err == nil && repo.singleCalls != 2 {
+				// guest has no privileges and admin is gone, so both are asked for alone
+				t.Errorf("expected 2 single calls, got %d", repo.singleCalls)
+			}
+		})
+	}
+}
+
+// countingPrivilegeRepository records the highest number of concurrent fetches
+type countingPrivilegeRepository struct {
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func (r *countingPrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	r.mu.Lock()
+	r.inFlight++
+	r.maxSeen = max(r.maxSeen, r.inFlight)
+	r.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+	return map[string]bool{"read:users": true}, nil
+}
+
+// countingStreamingRepository streams no role, so every role is then fetched alone
+type countingStreamingRepository struct {
+	countingPrivilegeRepository
+	passes int
+}
+
+func (r *countingStreamingRepository) FetchAll(ctx context.Context, fn func(roleID string, privileges map[string]bool) error) error {
+	r.passes++
+	return nil
+}
+
+func TestRBACService_RefreshCache_Concurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		streaming   bool
+		wantMax     int
+	}{
+		{name: "sequential by default", concurrency: 0, wantMax: 1},
+		{name: "bounded", concurrency: 3, wantMax: 3},
+		{name: "bounded after a pass", concurrency: 3, streaming: true, wantMax: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streaming := &countingStreamingRepository{}
+			repo := &streaming.countingPrivilegeRepository
+			var base PrivilegeRepository = repo
+			if tt.streaming {
+				base = streaming
+			}
+			svc := NewRBACService(base, 0, nil, WithRefreshConcurrency(tt.concurrency)).(*rbacService)
+
+			roleIDs := []string{"r1", "r2", "r3", "r4", "r5", "r6", "r7", "r8"}
+			svc.refreshCache(context.Background(), roleIDs)
+
+			if repo.maxSeen != tt.wantMax {
+				t.Errorf("expected at most %d concurrent fetches, got %d", tt.wantMax, repo.maxSeen)
+			}
+			if tt.streaming && streaming.passes != 1 {
+				// Without batch support, a streaming repository is read in one pass
+				t.Errorf("expected 1 pass, got %d", streaming.passes)
+			}
+			if svc.localCache().Len() != len(roleIDs) {
+				t.Errorf("expected %d cached roles, got %d", len(roleIDs), svc.localCache().Len())
+			}
+		})
+	}
+}
+
+func TestRBACService_NextRefreshDelay(t *testing.T) {
+	interval := time.Minute
+
+	svc, _ := newTestService(nil)
+	if got := svc.nextRefreshDelay(interval); got != interval {
+		t.Errorf("expected %v without jitter, got %v", interval, got)
+	}
+
+	svc, _ = newTestService(nil, WithRefreshJitter(10*time.Second))
+	for i := 0; i < 100; i++ {
+		got := svc.nextRefreshDelay(interval)
+		if got < interval || got >= interval+10*time.Second {
+			t.Fatalf("expected a delay in [%v, %v), got %v", interval, interval+10*time.Second, got)
+		}
+	}
+}
@@ -1,12 +1,89 @@
 package rbac
 
 import (
+	"container/list"
+	"context"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type RolePrivilegesCache struct {
-	mu    sync.RWMutex
-	cache map[string]map[string]bool
+	mu       sync.RWMutex
+	cache    map[string]map[string]bool
+	matchers map[string]*privilegeMatcher // compiled lazily from cache
+	bitsets  map[string]*compiledPrivileges
+	meta     map[string]entryMeta
+
+	// compiled interns the codes of every stored role and compiles its bitset up
+	// front; otherwise bitsets are compiled on the first handle check
+	compiled bool
+
+	// maxEntries bounds the cache when > 0; the least recently used role is evicted
+	maxEntries int
+	lru        *list.List               // front is most recently used
+	elements   map[string]*list.Element // role ID -> element in lru
+
+	// ttl limits how long an entry stays valid after it was stored when > 0
+	ttl time.Duration
+	// nextPurge is when a store next purges expired entries, so they do not pile
+	// up when no refresh runs
+	nextPurge time.Time
+	// onEvict is called, without holding mu, for every role evicted by the cache itself
+	onEvict func(roleID string, reason EvictionReason)
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+var _ PrivilegeCache = (*RolePrivilegesCache)(nil)
+
+// EvictionReason tells why the cache dropped a role on its own
+type EvictionReason int
+
+const (
+	// EvictedCapacity means the cache was full and the role was the least recently used
+	EvictedCapacity EvictionReason = iota + 1
+	// EvictedExpired means the entry outlived its TTL or one of its time-bounded grants
+	EvictedExpired
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictedCapacity:
+		return "capacity"
+	case EvictedExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+// cacheConfig holds the settings of a RolePrivilegesCache
+type cacheConfig struct {
+	maxEntries int
+	ttl        time.Duration
+	onEvict    func(roleID string, reason EvictionReason)
+	compiled   bool
+}
+
+// entryMeta holds bookkeeping for a cached role
+type entryMeta struct {
+	loadedAt time.Time
+
+	// expiresAt is when the entry stops being valid, e.g. because a time-bounded
+	// grant starts or ends. Zero means it never expires.
+	expiresAt time.Time
+
+	// origins maps each grant to the roles it was inherited from, when the entry
+	// was built from a role hierarchy
+	origins map[string][]string
+}
+
+// expired reports whether the entry is no longer valid at now
+func (m entryMeta) expired(now time.Time) bool {
+	return !m.expiresAt.IsZero() && !now.Before(m.expiresAt)
 }
 
 // NewRolePrivilegesCache creates a new RolePrivilegesCache
@@ -16,25 +93,190 @@ func NewRolePrivilegesCache() *RolePrivilegesCache {
 	}
 }
 
-// Get retrieves the privileges for a given role ID from the cache
+// newBoundedRolePrivilegesCache creates a RolePrivilegesCache holding at most maxEntries roles
+func newBoundedRolePrivilegesCache(maxEntries int) *RolePrivilegesCache {
+	return newConfiguredRolePrivilegesCache(cacheConfig{maxEntries: maxEntries})
+}
+
+// newConfiguredRolePrivilegesCache creates a RolePrivilegesCache from config
+func newConfiguredRolePrivilegesCache(config cacheConfig) *RolePrivilegesCache {
+	c := NewRolePrivilegesCache()
+	if config.maxEntries > 0 {
+		c.maxEntries = config.maxEntries
+		c.lru = list.New()
+		c.elements = make(map[string]*list.Element)
+	}
+	c.ttl = config.ttl
+	c.onEvict = config.onEvict
+	c.compiled = config.compiled
+	return c
+}
+
+// Get retrieves the privileges for a given role ID from the cache as a new map,
+// which the caller may modify. An expired entry is reported as missing and evicted.
 func (c *RolePrivilegesCache) Get(roleID string) (map[string]bool, bool) {
+	privileges, _, exist := c.getEntry(roleID)
+	if !exist {
+		return nil, false
+	}
+	return PrivilegeSetFromMap(privileges).ToMap(), true
+}
+
+// GetEntry retrieves the cached entry of a role, implementing PrivilegeCache
+func (c *RolePrivilegesCache) GetEntry(ctx context.Context, roleID string) (PrivilegeCacheEntry, bool, error) {
+	privileges, meta, exist := c.getEntry(roleID)
+	if !exist {
+		c.misses.Add(1)
+		return PrivilegeCacheEntry{}, false, nil
+	}
+
+	c.hits.Add(1)
+	return PrivilegeCacheEntry{
+		// Stored maps are never modified, so the set can share them
+		Privileges: PrivilegeSet{codes: privileges},
+		LoadedAt:   meta.loadedAt,
+		ExpiresAt:  meta.expiresAt,
+		Origins:    meta.origins,
+	}, true, nil
+}
+
+// getEntry is Get returning the entry's bookkeeping as well
+func (c *RolePrivilegesCache) getEntry(roleID string) (map[string]bool, entryMeta, bool) {
+	privileges, meta, exist, expired := c.lookup(roleID)
+	if expired {
+		c.deleteIfExpired(roleID)
+	}
+	return privileges, meta, exist
+}
+
+// lookup returns the entry of a role and whether it has expired
+func (c *RolePrivilegesCache) lookup(roleID string) (map[string]bool, entryMeta, bool, bool) {
+	if c.maxEntries > 0 {
+		// Recording the access mutates the LRU list
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		privileges, exist := c.cache[roleID]
+		if !exist {
+			return nil, entryMeta{}, false, false
+		}
+		meta := c.meta[roleID]
+		if meta.expired(time.Now()) {
+			return nil, entryMeta{}, false, true
+		}
+		c.lru.MoveToFront(c.elements[roleID])
+		return privileges, meta, true, false
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	privileges, exist := c.cache[roleID]
 	if !exist {
-		return nil, false
+		return nil, entryMeta{}, false, false
+	}
+	meta := c.meta[roleID]
+	if meta.expired(time.Now()) {
+		return nil, entryMeta{}, false, true
 	}
 
-	return privileges, true
+	return privileges, meta, true, false
 }
 
-// Set sets the privileges for a given role ID in the cache
+// Set sets the privileges for a given role ID in the cache. The cache keeps a copy,
+// so later changes to privileges do not affect it.
 func (c *RolePrivilegesCache) Set(roleID string, privileges map[string]bool) {
+	c.SetWithExpiry(roleID, privileges, time.Time{})
+}
+
+// SetWithExpiry sets the privileges for a given role ID in the cache until expiresAt.
+// After that Get reports the role as missing. A zero expiresAt never expires.
+func (c *RolePrivilegesCache) SetWithExpiry(roleID string, privileges map[string]bool, expiresAt time.Time) {
+	c.setEntry(roleID, PrivilegeSetFromMap(privileges).codes, entryMeta{loadedAt: time.Now(), expiresAt: expiresAt})
+}
+
+// setEntry stores privileges together with their bookkeeping. With a TTL the entry
+// expires at the earlier of meta.expiresAt and the end of the TTL.
+func (c *RolePrivilegesCache) setEntry(roleID string, privileges map[string]bool, meta entryMeta) {
+	if c.ttl > 0 {
+		meta.expiresAt = earliest(meta.expiresAt, meta.loadedAt.Add(c.ttl))
+	}
+
+	var compiled *compiledPrivileges
+	if c.compiled {
+		privileges = internPrivileges(privileges)
+		compiled = compilePrivileges(privileges)
+	}
+
+	expired, evicted := c.store(roleID, privileges, compiled, meta)
+	c.notifyEvicted(expired, EvictedExpired)
+	c.notifyEvicted(evicted, EvictedCapacity)
+}
+
+// SetEntry stores the entry of a role, implementing PrivilegeCache
+func (c *RolePrivilegesCache) SetEntry(ctx context.Context, roleID string, entry PrivilegeCacheEntry) error {
+	privileges := entry.Privileges.codes
+	if privileges == nil {
+		privileges = map[string]bool{}
+	}
+	c.setEntry(roleID, privileges, entryMeta{
+		loadedAt:  entry.LoadedAt,
+		expiresAt: entry.ExpiresAt,
+		origins:   entry.Origins,
+	})
+	return nil
+}
+
+// store writes an entry, with its bitset if already compiled, and returns the roles
+// whose expired entries it purged and the roles evicted to make room for it. With a
+// TTL it purges expired entries at most once per TTL.
+func (c *RolePrivilegesCache) store(roleID string, privileges map[string]bool, compiled *compiledPrivileges, meta entryMeta) (expired, evicted []string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if now := time.Now(); c.ttl > 0 && !now.Before(c.nextPurge) {
+		expired = c.purgeExpiredLocked(now)
+		c.nextPurge = now.Add(c.ttl)
+	}
+
 	c.cache[roleID] = privileges
+	delete(c.matchers, roleID)
+	delete(c.bitsets, roleID)
+	if compiled != nil {
+		if c.bitsets == nil {
+			c.bitsets = make(map[string]*compiledPrivileges)
+		}
+		c.bitsets[roleID] = compiled
+	}
+
+	if c.meta == nil {
+		c.meta = make(map[string]entryMeta)
+	}
+	c.meta[roleID] = meta
+
+	if c.maxEntries > 0 {
+		if element, ok := c.elements[roleID]; ok {
+			c.lru.MoveToFront(element)
+		} else {
+			c.elements[roleID] = c.lru.PushFront(roleID)
+		}
+		for len(c.cache) > c.maxEntries {
+			oldest := c.lru.Back().Value.(string)
+			c.removeLocked(oldest)
+			evicted = append(evicted, oldest)
+		}
+	}
+	return expired, evicted
+}
+
+// notifyEvicted reports evicted roles to onEvict; c.mu must not be held
+func (c *RolePrivilegesCache) notifyEvicted(roleIDs []string, reason EvictionReason) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, roleID := range roleIDs {
+		c.onEvict(roleID, reason)
+	}
 }
 
 // Delete deletes the privileges for a given role ID from the cache
@@ -42,7 +284,26 @@ func (c *RolePrivilegesCache) Delete(roleID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.removeLocked(roleID)
+}
+
+// DeleteEntry deletes the entry of a role, implementing PrivilegeCache
+func (c *RolePrivilegesCache) DeleteEntry(ctx context.Context, roleID string) error {
+	c.Delete(roleID)
+	return nil
+}
+
+// removeLocked drops a role from the cache; c.mu must be held
+func (c *RolePrivilegesCache) removeLocked(roleID string) {
 	delete(c.cache, roleID)
+	delete(c.matchers, roleID)
+	delete(c.bitsets, roleID)
+	delete(c.meta, roleID)
+
+	if element, ok := c.elements[roleID]; ok {
+		c.lru.Remove(element)
+		delete(c.elements, roleID)
+	}
 }
 
 // ClearCache clears the cache
@@ -51,6 +312,100 @@ func (c *RolePrivilegesCache) ClearCache() {
 	defer c.mu.Unlock()
 
 	c.cache = make(map[string]map[string]bool)
+	c.matchers = nil
+	c.bitsets = nil
+	c.meta = nil
+
+	if c.maxEntries > 0 {
+		c.lru.Init()
+		c.elements = make(map[string]*list.Element)
+	}
+}
+
+// Clear clears the cache, implementing PrivilegeCache
+func (c *RolePrivilegesCache) Clear(ctx context.Context) error {
+	c.ClearCache()
+	return nil
+}
+
+// Keys returns all role IDs in the cache, implementing PrivilegeCache
+func (c *RolePrivilegesCache) Keys(ctx context.Context) ([]string, error) {
+	return c.GetAllKeys(), nil
+}
+
+// Stats returns the cache's own metrics, implementing PrivilegeCache
+func (c *RolePrivilegesCache) Stats() CacheStats {
+	return CacheStats{
+		Entries: c.Len(),
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
+
+// deleteIfExpired removes a role only if its entry has expired, so a newer entry
+// stored in the meantime survives
+func (c *RolePrivilegesCache) deleteIfExpired(roleID string) bool {
+	c.mu.Lock()
+	_, exist := c.cache[roleID]
+	expired := exist && c.meta[roleID].expired(time.Now())
+	if expired {
+		c.removeLocked(roleID)
+	}
+	c.mu.Unlock()
+
+	if expired {
+		c.notifyEvicted([]string{roleID}, EvictedExpired)
+	}
+	return expired
+}
+
+// purgeExpired removes every expired entry and returns their roles
+func (c *RolePrivilegesCache) purgeExpired() []string {
+	c.mu.Lock()
+	expired := c.purgeExpiredLocked(time.Now())
+	c.mu.Unlock()
+
+	c.notifyEvicted(expired, EvictedExpired)
+	return expired
+}
+
+// purgeExpiredLocked removes every entry expired at now and returns their roles,
+// sorted; c.mu must be held
+func (c *RolePrivilegesCache) purgeExpiredLocked(now time.Time) []string {
+	var expired []string
+	for roleID, meta := range c.meta {
+		if _, exist := c.cache[roleID]; exist && meta.expired(now) {
+			expired = append(expired, roleID)
+		}
+	}
+	slices.Sort(expired)
+	for _, roleID := range expired {
+		c.removeLocked(roleID)
+	}
+	return expired
+}
+
+// oldestLoadedAt returns the load time of the oldest entry, zero if the cache is empty
+func (c *RolePrivilegesCache) oldestLoadedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var oldest time.Time
+	for roleID := range c.cache {
+		loadedAt := c.meta[roleID].loadedAt
+		if oldest.IsZero() || loadedAt.Before(oldest) {
+			oldest = loadedAt
+		}
+	}
+	return oldest
+}
+
+// Len returns the number of cached roles
+func (c *RolePrivilegesCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.cache)
 }
 
 // GetAllKeys returns all role IDs in the cache
@@ -64,3 +419,121 @@ func (c *RolePrivilegesCache) GetAllKeys() []string {
 	}
 	return keys
 }
+
+// matcher returns the compiled matcher for a given role ID, building it on first use
+func (c *RolePrivilegesCache) matcher(roleID string) (*privilegeMatcher, bool) {
+	c.mu.RLock()
+	m, ok := c.matchers[roleID]
+	expired := c.meta[roleID].expired(time.Now())
+	c.mu.RUnlock()
+	if expired {
+		return nil, false
+	}
+	if ok {
+		return m, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	privileges, exist := c.cache[roleID]
+	if !exist || c.meta[roleID].expired(time.Now()) {
+		return nil, false
+	}
+	if m, ok := c.matchers[roleID]; ok {
+		return m, true
+	}
+
+	m = newPrivilegeMatcher(privileges)
+	if c.matchers == nil {
+		c.matchers = make(map[string]*privilegeMatcher)
+	}
+	c.matchers[roleID] = m
+	return m, true
+}
+
+// HasPrivilegeHandle reports whether a cached role has a privilege, with the same
+// rules as RBACService.HasPrivilege, and whether the role is cached at all. The
+// role's bitset is compiled on first use unless the cache compiles up front.
+func (c *RolePrivilegesCache) HasPrivilegeHandle(roleID string, privilege PrivilegeHandle) (bool, bool) {
+	compiled, ok := c.bitset(roleID)
+	if !ok {
+		return false, false
+	}
+	return compiled.has(privilege), true
+}
+
+// compiledEntry returns the compiled bitset of a cached role and when the role was
+// loaded, in a single lookup that counts as a hit. The bitset is compiled on first
+// use. A missing or expired role is reported as false without counting a miss, so
+// the caller can go on with GetEntry.
+func (c *RolePrivilegesCache) compiledEntry(roleID string) (*compiledPrivileges, time.Time, bool) {
+	if c.maxEntries == 0 {
+		c.mu.RLock()
+		compiled, ok := c.bitsets[roleID]
+		meta := c.meta[roleID]
+		c.mu.RUnlock()
+		if ok {
+			if meta.expired(time.Now()) {
+				return nil, time.Time{}, false
+			}
+			c.hits.Add(1)
+			return compiled, meta.loadedAt, true
+		}
+	}
+
+	// Recording the access or compiling the bitset mutates the cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	privileges, exist := c.cache[roleID]
+	meta := c.meta[roleID]
+	if !exist || meta.expired(time.Now()) {
+		return nil, time.Time{}, false
+	}
+	if c.maxEntries > 0 {
+		c.lru.MoveToFront(c.elements[roleID])
+	}
+	compiled, ok := c.bitsets[roleID]
+	if !ok {
+		compiled = compilePrivileges(privileges)
+		if c.bitsets == nil {
+			c.bitsets = make(map[string]*compiledPrivileges)
+		}
+		c.bitsets[roleID] = compiled
+	}
+	c.hits.Add(1)
+	return compiled, meta.loadedAt, true
+}
+
+// bitset returns the compiled bitset for a given role ID, building it on first use
+func (c *RolePrivilegesCache) bitset(roleID string) (*compiledPrivileges, bool) {
+	c.mu.RLock()
+	compiled, ok := c.bitsets[roleID]
+	expired := c.meta[roleID].expired(time.Now())
+	c.mu.RUnlock()
+	if expired {
+		return nil, false
+	}
+	if ok {
+		return compiled, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	privileges, exist := c.cache[roleID]
+	if !exist || c.meta[roleID].expired(time.Now()) {
+		return nil, false
+	}
+	if compiled, ok := c.bitsets[roleID]; ok {
+		return compiled, true
+	}
+
+	compiled = compilePrivileges(privileges)
+	if c.bitsets == nil {
+		c.bitsets = make(map[string]*compiledPrivileges)
+	}
+	c.bitsets[roleID] = compiled
+	return compiled, true
+}
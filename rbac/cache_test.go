@@ -2,8 +2,10 @@ package rbac
 
 import (
 	"reflect"
+	"sort"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestRolePrivilegesCache_Get(t *testing.T) {
@@ -388,3 +390,110 @@ func TestRolePrivilegesCache_GetAllKeys(t *testing.T) {
 		})
 	}
 }
+
+func TestRolePrivilegesCache_Eviction(t *testing.T) {
+	type eviction struct {
+		roleID string
+		reason EvictionReason
+	}
+	tests := []struct {
+		name       string
+		config     cacheConfig
+		wait       time.Duration
+		wantKeys   []string
+		wantEvicts []eviction
+	}{
+		{
+			name:     "unbounded",
+			config:   cacheConfig{},
+			wantKeys: []string{"role1", "role2", "role3"},
+		},
+		{
+			name:       "least recently used evicted",
+			config:     cacheConfig{maxEntries: 2},
+			wantKeys:   []string{"role1", "role3"},
+			wantEvicts: []eviction{{"role2", EvictedCapacity}},
+		},
+		{
+			name:   "expired by ttl",
+			config: cacheConfig{ttl: time.Millisecond},
+			wait:   5 * time.Millisecond,
+			wantEvicts: []eviction{
+				{"role1", EvictedExpired},
+				{"role2", EvictedExpired},
+				{"role3", EvictedExpired},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evicts []eviction
+			tt.config.onEvict = func(roleID string, reason EvictionReason) {
+				evicts = append(evicts, eviction{roleID, reason})
+			}
+			c := newConfiguredRolePrivilegesCache(tt.config)
+
+			c.Set("role1", map[string]bool{"privilege1": true})
+			c.Set("role2", map[string]bool{"privilege2": true})
+			c.Get("role1") // role2 becomes the least recently used
+			c.Set("role3", map[string]bool{"privilege3": true})
+
+			time.Sleep(tt.wait)
+			c.purgeExpired()
+
+			keys := c.GetAllKeys()
+			sort.Strings(keys)
+			if len(keys) == 0 {
+				keys = nil
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+			sort.Slice(evicts, func(i, j int) bool { return evicts[i].roleID < evicts[j].roleID })
+			if !reflect.DeepEqual(evicts, tt.wantEvicts) {
+				t.Errorf("evictions = %v, want %v", evicts, tt.wantEvicts)
+			}
+		})
+	}
+}
+
+func TestRolePrivilegesCache_GetExpired(t *testing.T) {
+	var evicted []string
+	c := newConfiguredRolePrivilegesCache(cacheConfig{
+		ttl: time.Millisecond,
+		onEvict: func(roleID string, reason EvictionReason) {
+			evicted = append(evicted, roleID)
+		},
+	})
+	c.Set("role1", map[string]bool{"privilege1": true})
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("role1"); ok {
+		t.Fatal("expected expired role to be missing")
+	}
+	if c.Len() != 0 || !reflect.DeepEqual(evicted, []string{"role1"}) {
+		t.Errorf("expected role1 evicted, got len %d and %v", c.Len(), evicted)
+	}
+}
+
+func TestRolePrivilegesCache_PurgedOnStore(t *testing.T) {
+	var evicted []string
+	c := newConfiguredRolePrivilegesCache(cacheConfig{
+		ttl: 10 * time.Millisecond,
+		onEvict: func(roleID string, reason EvictionReason) {
+			evicted = append(evicted, roleID)
+		},
+	})
+	c.Set("role1", map[string]bool{"privilege1": true})
+
+	// Without a refresh, storing another role drops the expired one
+	time.Sleep(20 * time.Millisecond)
+	c.Set("role2", map[string]bool{"privilege2": true})
+
+	if got := c.Stats().Entries; got != 1 {
+		t.Errorf("expected 1 entry, got %d", got)
+	}
+	if !reflect.DeepEqual(evicted, []string{"role1"}) {
+		t.Errorf("expected role1 evicted, got %v", evicted)
+	}
+}
@@ -0,0 +1,218 @@
+package rbac
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+)
+
+// RoleChange describes how the privileges of a role changed since it was last loaded.
+// A role the repository no longer knows reports everything it held as Removed. A
+// role whose entry expired is compared when it is loaded again; if the expired entry
+// is purged first, the role is forgotten without a change, as no grant ended.
+type RoleChange struct {
+	TenantID string // empty outside any tenant
+	RoleID   string
+	Added    []string // codes the role gained, sorted
+	Removed  []string // codes the role lost, sorted; revocations show up here
+}
+
+// roleChangeSubscribers holds the handlers registered with OnRoleChanged
+type roleChangeSubscribers struct {
+	mu       sync.RWMutex
+	next     int
+	handlers map[int]func(RoleChange)
+}
+
+func (r *roleChangeSubscribers) add(handler func(RoleChange)) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.handlers == nil {
+		r.handlers = make(map[int]func(RoleChange))
+	}
+	id := r.next
+	r.next++
+	r.handlers[id] = handler
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			delete(r.handlers, id)
+		})
+	}
+}
+
+func (r *roleChangeSubscribers) subscribed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.handlers) > 0
+}
+
+func (r *roleChangeSubscribers) list() []func(RoleChange) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handlers := make([]func(RoleChange), 0, len(r.handlers))
+	for _, handler := range r.handlers {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
+// OnRoleChanged calls handler whenever reloading a role, e.g. by the periodic
+// refresh, changes its privileges, and when a role leaves the cache without a
+// reload (see RoleChange). Handlers run on the goroutine that reloaded the
+// role, possibly concurrently, and must not block. Call the returned function to
+// stop receiving changes.
+func (s *rbacService) OnRoleChanged(handler func(RoleChange)) (unsubscribe func()) {
+	return s.changes.add(handler)
+}
+
+// roleReloaded compares the privileges a role was reloaded with to the ones it was
+// last loaded with, logging and reporting a change. It returns whether they differ.
+func (s *rbacService) roleReloaded(tenantID, roleID string, previous, privileges PrivilegeSet) bool {
+	if previous.Equal(privileges) {
+		return false
+	}
+
+	change := RoleChange{
+		TenantID: tenantID,
+		RoleID:   roleID,
+		Added:    privileges.Difference(previous).List(),
+		Removed:  previous.Difference(privileges).List(),
+	}
+
+	if tenantID == "" {
+		s.logger.Debugf("Role %s gained [%s] and lost [%s]", roleID,
+			strings.Join(change.Added, ", "), strings.Join(change.Removed, ", "))
+	} else {
+		s.logger.Debugf("Role %s of tenant %s gained [%s] and lost [%s]", roleID, tenantID,
+			strings.Join(change.Added, ", "), strings.Join(change.Removed, ", "))
+	}
+
+	for _, handler := range s.changes.list() {
+		handler(change)
+	}
+	return true
+}
+
+// maxLoadedSets bounds how many roles loadedSets remembers by default. Its size
+// does not follow the caches it sits beside, which may be external and expire
+// entries on their own, so without a bound unknown role IDs would pile up.
+const maxLoadedSets = 10000
+
+// loadedSets remembers the privileges each role was last loaded with, so a reload
+// reports what changed even when the cached entry expired or was dropped in between.
+// Beyond its limit the least recently loaded role is forgotten, and its next load
+// reports nothing.
+type loadedSets struct {
+	mu       sync.Mutex
+	limit    int                      // 0 means maxLoadedSets
+	lru      *list.List               // of *loadedSet, front is most recently loaded
+	elements map[string]*list.Element // tenantRoleKey -> element in lru
+}
+
+type loadedSet struct {
+	key        string
+	privileges PrivilegeSet
+}
+
+// swap records privileges as the last loaded set of key and returns the previous one
+func (l *loadedSets) swap(key string, privileges PrivilegeSet) (PrivilegeSet, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.elements == nil {
+		l.lru = list.New()
+		l.elements = make(map[string]*list.Element)
+	}
+	if element, ok := l.elements[key]; ok {
+		set := element.Value.(*loadedSet)
+		previous := set.privileges
+		set.privileges = privileges
+		l.lru.MoveToFront(element)
+		return previous, true
+	}
+
+	l.elements[key] = l.lru.PushFront(&loadedSet{key: key, privileges: privileges})
+	limit := l.limit
+	if limit <= 0 {
+		limit = maxLoadedSets
+	}
+	for l.lru.Len() > limit {
+		oldest := l.lru.Remove(l.lru.Back()).(*loadedSet)
+		delete(l.elements, oldest.key)
+	}
+	return PrivilegeSet{}, false
+}
+
+// remove forgets key and returns its last loaded set
+func (l *loadedSets) remove(key string) (PrivilegeSet, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.elements[key]
+	if !ok {
+		return PrivilegeSet{}, false
+	}
+	l.lru.Remove(element)
+	delete(l.elements, key)
+	return element.Value.(*loadedSet).privileges, true
+}
+
+// removeTenant forgets every key of a tenant
+func (l *loadedSets) removeTenant(tenantID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prefix := tenantRoleKey(tenantID, "")
+	for key, element := range l.elements {
+		if tenantID == "" && strings.Contains(key, "\x00") {
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			l.lru.Remove(element)
+			delete(l.elements, key)
+		}
+	}
+}
+
+// len returns the number of remembered roles
+func (l *loadedSets) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.elements)
+}
+
+// roleRemoved reports everything a role was last loaded with as Removed and
+// forgets it, for a role leaving the cache without a reload
+func (s *rbacService) roleRemoved(tenantID, roleID string) {
+	if previous, ok := s.loaded.remove(tenantRoleKey(tenantID, roleID)); ok {
+		s.roleReloaded(tenantID, roleID, previous, PrivilegeSet{})
+	}
+}
+
+// reloadExpired reloads a role whose entry just expired, so handlers learn right
+// away which grants started or ended. Without handlers the role is only forgotten;
+// if it cannot be reloaded, everything it held is reported as Removed.
+func (s *rbacService) reloadExpired(tenantID, roleID string) {
+	if !s.changes.subscribed() || s.isClosed() {
+		s.loaded.remove(tenantRoleKey(tenantID, roleID))
+		return
+	}
+
+	ctx := context.Background()
+	if tenantID != "" {
+		ctx = InjectTenant(ctx, tenantID)
+	}
+	if _, err := s.loadRolePrivileges(ctx, roleID); err != nil {
+		s.logger.Errorf("Error reloading expired role %s: %v", roleID, err)
+		s.roleRemoved(tenantID, roleID)
+	}
+}
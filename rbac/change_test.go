@@ -0,0 +1,296 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger keeps every debug message
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {}
+
+func TestRBACService_OnRoleChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]bool
+		after  map[string]bool
+		want   []RoleChange
+	}{
+		{
+			name:   "unchanged",
+			before: map[string]bool{"read:users": true},
+			after:  map[string]bool{"read:users": true},
+			want:   nil,
+		},
+		{
+			name:   "revoked code is not a change",
+			before: map[string]bool{"read:users": true},
+			after:  map[string]bool{"read:users": true, "write:users": false},
+			want:   nil,
+		},
+		{
+			name:   "gained and lost",
+			before: map[string]bool{"read:users": true, "write:users": true},
+			after:  map[string]bool{"read:users": true, "delete:users": true, "export:data": true},
+			want: []RoleChange{{
+				RoleID:  "viewer",
+				Added:   []string{"delete:users", "export:data"},
+				Removed: []string{"write:users"},
+			}},
+		},
+		{
+			name:   "everything revoked",
+			before: map[string]bool{"read:users": true},
+			after:  map[string]bool{},
+			want:   []RoleChange{{RoleID: "viewer", Added: []string{}, Removed: []string{"read:users"}}},
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := newTestService(map[string]map[string]bool{"viewer": tt.before})
+			ctx := context.Background()
+
+			var got []RoleChange
+			svc.OnRoleChanged(func(change RoleChange) {
+				got = append(got, change)
+			})
+
+			// The first load is not a change
+			if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+				t.Fatalf("GetRolePrivileges() error = %v", err)
+			}
+			repo.privileges["viewer"] = tt.after
+			svc.refreshCache(ctx, []string{"viewer"})
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OnRoleChanged() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBACService_OnRoleChanged_Tenant(t *testing.T) {
+	repo := &mockTenantPrivilegeRepository{tenants: map[string]map[string]map[string]bool{
+		"acme": {"viewer": {"read:users": true}},
+	}}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+	ctx := InjectTenant(context.Background(), "acme")
+
+	var got []RoleChange
+	svc.OnRoleChanged(func(change RoleChange) {
+		got = append(got, change)
+	})
+
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("GetRolePrivileges() error = %v", err)
+	}
+	repo.tenants["acme"]["viewer"] = map[string]bool{"write:users": true}
+	svc.refreshCache(ctx, []string{"viewer"})
+
+	want := []RoleChange{{TenantID: "acme", RoleID: "viewer", Added: []string{"write:users"}, Removed: []string{"read:users"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnRoleChanged() got = %+v, want %+v", got, want)
+	}
+}
+
+func TestRBACService_OnRoleChanged_Inheritance(t *testing.T) {
+	parents := &mockRoleParentRepository{parents: map[string][]string{"editor": {"viewer"}}}
+	svc, repo := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+	}, WithRoleParentRepository(parents))
+	ctx := context.Background()
+
+	var got []RoleChange
+	svc.OnRoleChanged(func(change RoleChange) {
+		got = append(got, change)
+	})
+
+	if _, err := svc.GetRolePrivileges(ctx, "editor"); err != nil {
+		t.Fatalf("GetRolePrivileges() error = %v", err)
+	}
+	repo.privileges["viewer"] = map[string]bool{"read:users": true, "export:data": true}
+	svc.refreshCache(ctx, []string{"editor"})
+
+	want := []RoleChange{{RoleID: "editor", Added: []string{"export:data"}, Removed: []string{}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnRoleChanged() got = %+v, want %+v", got, want)
+	}
+}
+
+func TestRBACService_OnRoleChanged_UnsubscribeAndLog(t *testing.T) {
+	logger := &recordingLogger{}
+	repo := &mockPrivilegeRepository{privileges: map[string]map[string]bool{"viewer": {"read:users": true}}}
+	svc := NewRBACService(repo, 0, logger).(*rbacService)
+	ctx := context.Background()
+
+	calls := 0
+	unsubscribe := svc.OnRoleChanged(func(change RoleChange) {
+		calls++
+	})
+
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("GetRolePrivileges() error = %v", err)
+	}
+	repo.privileges["viewer"] = map[string]bool{"write:users": true}
+	svc.refreshCache(ctx, []string{"viewer"})
+
+	unsubscribe()
+	unsubscribe()
+	repo.privileges["viewer"] = map[string]bool{"read:users": true}
+	svc.refreshCache(ctx, []string{"viewer"})
+
+	if calls != 1 {
+		t.Errorf("handler should run once before unsubscribing, ran %d times", calls)
+	}
+
+	logged := strings.Join(logger.messages, "\n")
+	for _, want := range []string{
+		"Role viewer gained [write:users] and lost [read:users]",
+		"Role viewer gained [read:users] and lost [write:users]",
+	} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected log %q, got %q", want, logged)
+		}
+	}
+}
+
+func TestRBACService_OnRoleChanged_DroppedEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		grant time.Duration // lifetime of a timed read:ledger grant, 0 for none
+		drop  func(svc *rbacService, repo *mockTimedPrivilegeRepository)
+		want  []RoleChange
+	}{
+		{
+			name: "not found",
+			drop: func(svc *rbacService, repo *mockTimedPrivilegeRepository) {
+				repo.missing = map[string]bool{"viewer": true}
+				svc.refreshCache(context.Background(), []string{"viewer"})
+			},
+			want: []RoleChange{{RoleID: "viewer", Added: []string{}, Removed: []string{"read:users"}}},
+		},
+		{
+			name: "purged after ttl",
+			opts: []Option{WithCacheTTL(time.Millisecond)},
+			drop: func(svc *rbacService, repo *mockTimedPrivilegeRepository) {
+				time.Sleep(5 * time.Millisecond)
+				svc.refreshCache(context.Background(), svc.cachedKeys(context.Background(), ""))
+
+				// Purged roles are forgotten, so loading one again reports nothing
+				repo.privileges["viewer"] = map[string]bool{"write:users": true}
+				if _, err := svc.GetRolePrivileges(context.Background(), "viewer"); err != nil {
+					t.Fatalf("GetRolePrivileges() error = %v", err)
+				}
+			},
+		},
+		{
+			name: "reloaded after ttl",
+			opts: []Option{WithCacheTTL(time.Millisecond)},
+			drop: func(svc *rbacService, repo *mockTimedPrivilegeRepository) {
+				repo.privileges["viewer"] = map[string]bool{"write:users": true}
+				time.Sleep(5 * time.Millisecond)
+				if _, err := svc.GetRolePrivileges(context.Background(), "viewer"); err != nil {
+					t.Fatalf("GetRolePrivileges() error = %v", err)
+				}
+			},
+			want: []RoleChange{{RoleID: "viewer", Added: []string{"write:users"}, Removed: []string{"read:users"}}},
+		},
+		{
+			name:  "timed grant ended",
+			grant: 20 * time.Millisecond,
+			drop: func(svc *rbacService, repo *mockTimedPrivilegeRepository) {
+				time.Sleep(60 * time.Millisecond)
+			},
+			want: []RoleChange{{RoleID: "viewer", Added: []string{}, Removed: []string{"read:ledger"}}},
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockTimedPrivilegeRepository{
+				mockPrivilegeRepository: mockPrivilegeRepository{privileges: map[string]map[string]bool{
+					"viewer": {"read:users": true},
+				}},
+			}
+			if tt.grant > 0 {
+				repo.grants = map[string][]PrivilegeGrant{
+					"viewer": {{Privilege: "read:ledger", ValidUntil: time.Now().Add(tt.grant)}},
+				}
+			}
+			svc := NewRBACService(repo, 0, nil, tt.opts...).(*rbacService)
+
+			var mu sync.Mutex
+			var got []RoleChange
+			svc.OnRoleChanged(func(change RoleChange) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, change)
+			})
+
+			if _, err := svc.GetRolePrivileges(context.Background(), "viewer"); err != nil {
+				t.Fatalf("GetRolePrivileges() error = %v", err)
+			}
+			tt.drop(svc, repo)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OnRoleChanged() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBACService_OnRoleChanged_LoadedSetsBounded(t *testing.T) {
+	svc, repo := newTestService(map[string]map[string]bool{
+		"viewer":  {"read:users": true},
+		"editor":  {"write:users": true},
+		"auditor": {"read:logs": true},
+	})
+	svc.loaded.limit = 2
+	ctx := context.Background()
+
+	var got []RoleChange
+	svc.OnRoleChanged(func(change RoleChange) {
+		got = append(got, change)
+	})
+
+	for _, roleID := range []string{"viewer", "editor", "auditor"} {
+		if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+			t.Fatalf("GetRolePrivileges() error = %v", err)
+		}
+	}
+	if n := svc.loaded.len(); n != 2 {
+		t.Errorf("expected 2 remembered roles, got %d", n)
+	}
+
+	// The least recently loaded role was forgotten, so only the other reports
+	repo.privileges["viewer"] = map[string]bool{}
+	repo.privileges["auditor"] = map[string]bool{}
+	svc.refreshCache(ctx, []string{"viewer", "auditor"})
+
+	want := []RoleChange{{RoleID: "auditor", Added: []string{}, Removed: []string{"read:logs"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnRoleChanged() got = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package rbac
+
+import (
+	"strings"
+	"sync"
+)
+
+// privilegeRegistry interns privilege codes, numbering each distinct code once for
+// the whole process. Numbers are never reused, so the registry only grows with the
+// number of distinct codes ever seen.
+type privilegeRegistry struct {
+	mu    sync.RWMutex
+	ids   map[string]uint32
+	codes []string
+}
+
+// registry is shared by every service and cache, so a handle resolved once works
+// against any role
+var registry = newPrivilegeRegistry()
+
+func newPrivilegeRegistry() *privilegeRegistry {
+	// The empty code takes number 0, so the zero PrivilegeHandle matches no real grant
+	return &privilegeRegistry{ids: map[string]uint32{"": 0}, codes: []string{""}}
+}
+
+// intern returns the number of code, and the registry's copy of the string
+func (r *privilegeRegistry) intern(code string) (uint32, string) {
+	r.mu.RLock()
+	id, ok := r.ids[code]
+	if ok {
+		code = r.codes[id]
+	}
+	r.mu.RUnlock()
+	if ok {
+		return id, code
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, ok := r.ids[code]; ok {
+		return id, r.codes[id]
+	}
+	id = uint32(len(r.codes))
+	r.ids[code] = id
+	r.codes = append(r.codes, code)
+	return id, code
+}
+
+// PrivilegeHandle is a privilege code resolved ahead of time with ResolvePrivilege.
+// Checking a handle against a compiled role is a bit test instead of string lookups.
+// Handles are valid for the lifetime of the process and safe to share.
+type PrivilegeHandle struct {
+	id   uint32
+	code string
+}
+
+// ResolvePrivilege returns the handle of a privilege code. Resolve the privileges
+// checked on hot paths once, e.g. into package-level variables:
+//
+//	var readUsers = rbac.ResolvePrivilege("read:users")
+func ResolvePrivilege(code string) PrivilegeHandle {
+	id, code := registry.intern(code)
+	return PrivilegeHandle{id: id, code: code}
+}
+
+// String returns the privilege code of the handle
+func (h PrivilegeHandle) String() string {
+	return h.code
+}
+
+// bitset is a set of registry numbers
+type bitset []uint64
+
+func (b *bitset) set(id uint32) {
+	word := int(id / 64)
+	if word >= len(*b) {
+		grown := make(bitset, word+1)
+		copy(grown, *b)
+		*b = grown
+	}
+	(*b)[word] |= 1 << (id % 64)
+}
+
+func (b bitset) has(id uint32) bool {
+	word := int(id / 64)
+	return word < len(b) && b[word]&(1<<(id%64)) != 0
+}
+
+// compiledPrivileges is the bitset form of a role's privileges. Exact grants and
+// denies are bits; wildcard grants and denies keep using a privilegeMatcher.
+type compiledPrivileges struct {
+	allow bitset
+	deny  bitset // numbers of the denied codes, without DenyPrefix
+
+	// wildcards answers checks when the role has wildcard grants or denies;
+	// nil otherwise, which is the case checks are fastest for
+	wildcards *privilegeMatcher
+}
+
+// compilePrivileges compiles the granted entries of privileges, interning every code
+func compilePrivileges(privileges map[string]bool) *compiledPrivileges {
+	c := &compiledPrivileges{}
+	wildcards := false
+
+	for code, granted := range privileges {
+		if !granted {
+			continue
+		}
+
+		if denied, ok := strings.CutPrefix(code, DenyPrefix); ok {
+			if isWildcardGrant(denied) {
+				wildcards = true
+				continue
+			}
+			id, _ := registry.intern(denied)
+			c.deny.set(id)
+			continue
+		}
+
+		if isWildcardGrant(code) {
+			wildcards = true
+			continue
+		}
+		id, _ := registry.intern(code)
+		c.allow.set(id)
+	}
+
+	if wildcards {
+		c.wildcards = newPrivilegeMatcher(privileges)
+	}
+	return c
+}
+
+// has reports whether privilege is allowed and not explicitly denied, with the
+// same rules as privilegeMatcher.has
+func (c *compiledPrivileges) has(privilege PrivilegeHandle) bool {
+	if c.deny.has(privilege.id) {
+		return false
+	}
+	if c.wildcards != nil {
+		return c.wildcards.has(privilege.code)
+	}
+	return c.allow.has(privilege.id)
+}
+
+// internPrivileges returns privileges keyed by the registry's copies of the codes,
+// so roles sharing a privilege share one string
+func internPrivileges(privileges map[string]bool) map[string]bool {
+	interned := make(map[string]bool, len(privileges))
+	for code, granted := range privileges {
+		if granted {
+			_, code = registry.intern(code)
+			interned[code] = true
+		}
+	}
+	return interned
+}
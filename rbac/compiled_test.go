@@ -0,0 +1,216 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+func TestCompiledPrivileges_Has(t *testing.T) {
+	privileges := []string{"users:read", "users:write", "users:delete", "users:read:own", "users", "export:data", "export:pdf", "reports:read", "a:b:read", "anything:at:all"}
+
+	tests := []struct {
+		name   string
+		grants map[string]bool
+	}{
+		{name: "exact grants", grants: map[string]bool{"users:read": true, "export:data": true}},
+		{name: "false is not a grant", grants: map[string]bool{"users:read": false}},
+		{name: "trailing wildcard", grants: map[string]bool{"users:*": true}},
+		{name: "leading wildcard", grants: map[string]bool{"*:read": true}},
+		{name: "global wildcard with deny", grants: map[string]bool{"*": true, "!export:data": true}},
+		{name: "exact deny", grants: map[string]bool{"export:data": true, "!export:data": true, "users:read": true}},
+		{name: "wildcard deny", grants: map[string]bool{"export:pdf": true, "export:data": true, "!export:*": true}},
+		{name: "deny alone", grants: map[string]bool{"!export:data": true}},
+		{name: "no grants", grants: map[string]bool{}},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			compiled := compilePrivileges(tt.grants)
+			matcher := newPrivilegeMatcher(tt.grants)
+			for _, privilege := range privileges {
+				if got, want := compiled.has(ResolvePrivilege(privilege)), matcher.has(privilege); got != want {
+					t.Errorf("compiledPrivileges.has(%q) got = %v, want %v", privilege, got, want)
+				}
+			}
+		})
+	}
+
+	if !compilePrivileges(map[string]bool{"": true, "users:read": true}).has(PrivilegeHandle{}) {
+		t.Error("the zero handle should stand for the empty code")
+	}
+	if compilePrivileges(map[string]bool{"users:read": true}).has(PrivilegeHandle{}) {
+		t.Error("the zero handle should not match real grants")
+	}
+}
+
+func TestResolvePrivilege(t *testing.T) {
+	a := ResolvePrivilege("read:users")
+	b := ResolvePrivilege(string([]byte("read:users")))
+
+	if a != b {
+		t.Errorf("resolving the same code twice got %+v and %+v", a, b)
+	}
+	if a.String() != "read:users" {
+		t.Errorf("String() got = %q", a.String())
+	}
+	if ResolvePrivilege("write:users") == a {
+		t.Error("different codes should resolve to different handles")
+	}
+}
+
+func TestRolePrivilegesCache_HasPrivilegeHandle(t *testing.T) {
+	readUsers := ResolvePrivilege("read:users")
+	writeUsers := ResolvePrivilege("write:users")
+
+	for _, compiled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compiled=%v", compiled), func(t *testing.T) {
+			c := newConfiguredRolePrivilegesCache(cacheConfig{compiled: compiled})
+			c.Set("viewer", map[string]bool{"read:users": true})
+
+			if has, ok := c.HasPrivilegeHandle("viewer", readUsers); !has || !ok {
+				t.Errorf("HasPrivilegeHandle(read:users) got = %v, %v", has, ok)
+			}
+			if has, ok := c.HasPrivilegeHandle("viewer", writeUsers); has || !ok {
+				t.Errorf("HasPrivilegeHandle(write:users) got = %v, %v", has, ok)
+			}
+			if _, ok := c.HasPrivilegeHandle("editor", readUsers); ok {
+				t.Error("HasPrivilegeHandle() should report an uncached role")
+			}
+
+			// Replacing the role drops its old bitset
+			c.Set("viewer", map[string]bool{"write:users": true})
+			if has, _ := c.HasPrivilegeHandle("viewer", readUsers); has {
+				t.Error("HasPrivilegeHandle() used the bitset of the replaced entry")
+			}
+			if has, _ := c.HasPrivilegeHandle("viewer", writeUsers); !has {
+				t.Error("HasPrivilegeHandle() missed the privilege of the new entry")
+			}
+		})
+	}
+}
+
+func TestRolePrivilegesCache_CompiledInternsCodes(t *testing.T) {
+	c := newConfiguredRolePrivilegesCache(cacheConfig{compiled: true})
+	c.Set("viewer", map[string]bool{string([]byte("read:users")): true})
+	c.Set("editor", map[string]bool{string([]byte("read:users")): true})
+
+	var codes []string
+	for _, roleID := range []string{"viewer", "editor"} {
+		for code := range c.cache[roleID] {
+			codes = append(codes, code)
+		}
+	}
+	if unsafe.StringData(codes[0]) != unsafe.StringData(codes[1]) {
+		t.Error("roles sharing a privilege should share one string")
+	}
+}
+
+func TestRBACService_HasPrivilegeHandle(t *testing.T) {
+	privileges := map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"admin":  {"*": true, "!delete:users": true},
+	}
+
+	tests := []struct {
+		name      string
+		roleID    string
+		privilege string
+		want      bool
+	}{
+		{name: "exact grant", roleID: "viewer", privilege: "read:users", want: true},
+		{name: "missing grant", roleID: "viewer", privilege: "write:users", want: false},
+		{name: "wildcard grant", roleID: "admin", privilege: "write:users", want: true},
+		{name: "deny", roleID: "admin", privilege: "delete:users", want: false},
+	}
+
+	for _, opts := range [][]Option{nil, {WithCompiledPrivileges()}, {WithCompiledPrivileges(), WithCacheMaxEntries(10)}} {
+		svc, repo := newTestService(privileges, opts...)
+		for i := range tests {
+			tt := tests[i]
+			t.Run(fmt.Sprintf("%s/options=%d", tt.name, len(opts)), func(t *testing.T) {
+				got, err := svc.HasPrivilegeHandle(context.Background(), tt.roleID, ResolvePrivilege(tt.privilege))
+				if err != nil {
+					t.Fatalf("HasPrivilegeHandle() error = %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("HasPrivilegeHandle() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+		if repo.calls["viewer"] != 1 || repo.calls["admin"] != 1 {
+			t.Errorf("repository should be hit once per role, got %v", repo.calls)
+		}
+		if stats := svc.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+			t.Errorf("expected 2 hits and 2 misses, got %+v", stats)
+		}
+	}
+}
+
+// benchmarkCache fills a cache with roles holding the same privileges, like a
+// deployment where many roles share most codes
+func benchmarkCache(b *testing.B, compiled bool) *RolePrivilegesCache {
+	b.Helper()
+
+	c := newConfiguredRolePrivilegesCache(cacheConfig{compiled: compiled})
+	for role := 0; role < 200; role++ {
+		privileges := make(map[string]bool)
+		for code := 0; code < 100; code++ {
+			privileges[fmt.Sprintf("resource%d:action%d", code%20, code)] = true
+		}
+		c.Set(fmt.Sprintf("role%d", role), privileges)
+	}
+	return c
+}
+
+func BenchmarkRolePrivilegesCache_Matcher(b *testing.B) {
+	c := benchmarkCache(b, false)
+	privilege := "resource10:action50"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m, _ := c.matcher("role42")
+		if !m.has(privilege) {
+			b.Fatal("expected privilege")
+		}
+	}
+}
+
+func BenchmarkRolePrivilegesCache_Handle(b *testing.B) {
+	c := benchmarkCache(b, true)
+	privilege := ResolvePrivilege("resource10:action50")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if has, _ := c.HasPrivilegeHandle("role42", privilege); !has {
+			b.Fatal("expected privilege")
+		}
+	}
+}
+
+func BenchmarkRBACService_HasPrivilege(b *testing.B) {
+	svc, _ := newTestService(map[string]map[string]bool{"viewer": {"read:users": true, "write:users": true}})
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if has, _ := svc.HasPrivilege(ctx, "viewer", "write:users"); !has {
+			b.Fatal("expected privilege")
+		}
+	}
+}
+
+func BenchmarkRBACService_HasPrivilegeHandle(b *testing.B) {
+	svc, _ := newTestService(map[string]map[string]bool{"viewer": {"read:users": true, "write:users": true}}, WithCompiledPrivileges())
+	ctx := context.Background()
+	privilege := ResolvePrivilege("write:users")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if has, _ := svc.HasPrivilegeHandle(ctx, "viewer", privilege); !has {
+			b.Fatal("expected privilege")
+		}
+	}
+}
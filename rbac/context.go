@@ -10,9 +10,14 @@ type contextKey string
 // Context keys
 const (
 	RoleIDKey     contextKey = "roleID"
+	RoleIDsKey    contextKey = "roleIDs"
 	PrivilegesKey contextKey = "privileges"
 	UserIDKey     contextKey = "userID"
 	UserNameKey   contextKey = "userName"
+	TenantIDKey   contextKey = "tenantID"
+
+	// privilegeMatcherKey holds the matcher compiled from PrivilegesKey by the injectors
+	privilegeMatcherKey contextKey = "privilegeMatcher"
 )
 
 // GetRoleIDFromContext retrieves the role ID from the context
@@ -21,19 +26,92 @@ func GetRoleIDFromContext(ctx context.Context) (string, bool) {
 	return roleID, ok
 }
 
-// GetPrivilegesFromContext retrieves the privileges map from the context
+// GetRoleIDsFromContext retrieves the role IDs from the context.
+// Falls back to the single role ID when the context was built with InjectContext.
+func GetRoleIDsFromContext(ctx context.Context) ([]string, bool) {
+	if roleIDs, ok := ctx.Value(RoleIDsKey).([]string); ok {
+		return roleIDs, true
+	}
+	if roleID, ok := GetRoleIDFromContext(ctx); ok {
+		return []string{roleID}, true
+	}
+	return nil, false
+}
+
+// GetPrivilegesFromContext retrieves the privileges from the context as a new map,
+// which the caller may modify. See GetPrivilegeSetFromContext.
 func GetPrivilegesFromContext(ctx context.Context) (map[string]bool, bool) {
-	privileges, ok := ctx.Value(PrivilegesKey).(map[string]bool)
-	return privileges, ok
+	privileges, ok := GetPrivilegeSetFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	return privileges.ToMap(), true
 }
 
-// HasPrivilegeInContext checks if a specific privilege exists in the context
+// GetPrivilegeSetFromContext retrieves the privileges from the context. A map stored
+// directly under PrivilegesKey is accepted too.
+func GetPrivilegeSetFromContext(ctx context.Context) (PrivilegeSet, bool) {
+	switch privileges := ctx.Value(PrivilegesKey).(type) {
+	case PrivilegeSet:
+		return privileges, true
+	case map[string]bool:
+		return PrivilegeSetFromMap(privileges), true
+	default:
+		return PrivilegeSet{}, false
+	}
+}
+
+// HasPrivilegeInContext checks if a specific privilege exists in the context.
+// Wildcard grants such as "users:*" are honored.
 func HasPrivilegeInContext(ctx context.Context, privilegeCode string) bool {
-	privileges, ok := GetPrivilegesFromContext(ctx)
+	matcher, ok := getMatcherFromContext(ctx)
 	if !ok {
 		return false
 	}
-	return privileges[privilegeCode]
+	return matcher.has(privilegeCode)
+}
+
+// HasAnyPrivilegeInContext checks if any of the given privileges exists in the context
+func HasAnyPrivilegeInContext(ctx context.Context, privilegeCodes ...string) bool {
+	return MeetsRequirementInContext(ctx, AnyOf(privilegeCodes...))
+}
+
+// HasAllPrivilegesInContext checks if all of the given privileges exist in the context
+func HasAllPrivilegesInContext(ctx context.Context, privilegeCodes ...string) bool {
+	return MeetsRequirementInContext(ctx, AllOf(privilegeCodes...))
+}
+
+// MeetsRequirementInContext checks the privileges in the context against a compound requirement
+func MeetsRequirementInContext(ctx context.Context, req *Requirement) bool {
+	matcher, ok := getMatcherFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return req.Evaluate(matcher.has)
+}
+
+// MeetsExpressionInContext parses a requirement expression such as
+// "read:report && (export:pdf || export:csv)" and checks it against the context.
+// Parsed expressions are reused across calls.
+func MeetsExpressionInContext(ctx context.Context, expr string) (bool, error) {
+	req, err := parseRequirementCached(expr)
+	if err != nil {
+		return false, err
+	}
+	return MeetsRequirementInContext(ctx, req), nil
+}
+
+// getMatcherFromContext returns the compiled privileges stored by the injectors,
+// or compiles the raw privileges map if none was stored
+func getMatcherFromContext(ctx context.Context) (*privilegeMatcher, bool) {
+	if matcher, ok := ctx.Value(privilegeMatcherKey).(*privilegeMatcher); ok {
+		return matcher, true
+	}
+	privileges, ok := GetPrivilegeSetFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	return newPrivilegeMatcher(privileges.codes), true
 }
 
 // GetUserIDFromContext retrieves the user ID from the context
@@ -42,6 +120,12 @@ func GetUserIDFromContext(ctx context.Context) (string, bool) {
 	return userID, ok
 }
 
+// GetTenantIDFromContext retrieves the tenant ID from the context
+func GetTenantIDFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(TenantIDKey).(string)
+	return tenantID, ok
+}
+
 // GetUserNameFromContext retrieves the user name from the context
 func GetUserNameFromContext(ctx context.Context) (string, bool) {
 	userName, ok := ctx.Value(UserNameKey).(string)
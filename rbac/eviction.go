@@ -0,0 +1,23 @@
+package rbac
+
+// Eviction describes a role the cache dropped on its own
+type Eviction struct {
+	TenantID string // empty outside any tenant
+	RoleID   string
+	Reason   EvictionReason
+}
+
+// evicted keeps the role hierarchy in step with the caches and reports the eviction.
+// A role evicted for capacity did not change, so its last loaded set is forgotten
+// rather than reported.
+func (s *rbacService) evicted(tenantID, roleID string, reason EvictionReason) {
+	s.stats.evictions.Add(1)
+	s.hierarchy.untrack(tenantID, roleID)
+	if reason == EvictedCapacity {
+		s.loaded.remove(tenantRoleKey(tenantID, roleID))
+	}
+
+	if s.onEvict != nil {
+		s.onEvict(Eviction{TenantID: tenantID, RoleID: roleID, Reason: reason})
+	}
+}
@@ -0,0 +1,125 @@
+package rbac
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRBACService_CacheBounds(t *testing.T) {
+	privileges := map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+		"admin":  {"delete:users": true},
+	}
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		wait          time.Duration
+		wantEntries   int
+		wantEvictions []Eviction
+	}{
+		{
+			name:        "unbounded by default",
+			wantEntries: 3,
+		},
+		{
+			name:        "max entries",
+			opts:        []Option{WithCacheMaxEntries(2)},
+			wantEntries: 2,
+			wantEvictions: []Eviction{
+				{RoleID: "viewer", Reason: EvictedCapacity},
+			},
+		},
+		{
+			name:        "ttl",
+			opts:        []Option{WithCacheTTL(time.Millisecond)},
+			wait:        5 * time.Millisecond,
+			wantEntries: 3, // the expired roles are loaded again, the reload of editor purging the others
+			wantEvictions: []Eviction{
+				{RoleID: "editor", Reason: EvictedExpired},
+				{RoleID: "admin", Reason: EvictedExpired},
+				{RoleID: "viewer", Reason: EvictedExpired},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var evictions []Eviction
+			opts := append(tt.opts, WithEvictionCallback(func(e Eviction) {
+				mu.Lock()
+				defer mu.Unlock()
+				evictions = append(evictions, e)
+			}))
+			svc, repo := newTestService(privileges, opts...)
+			ctx := context.Background()
+
+			roles := []string{"viewer", "editor", "admin"}
+			for _, roleID := range roles {
+				if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			time.Sleep(tt.wait)
+			for _, roleID := range roles[1:] {
+				if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if tt.wait > 0 {
+				if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if got := svc.localCache().Len(); got != tt.wantEntries {
+				t.Errorf("expected %d entries, got %d", tt.wantEntries, got)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(evictions) != len(tt.wantEvictions) {
+				t.Fatalf("expected evictions %v, got %v", tt.wantEvictions, evictions)
+			}
+			for i, want := range tt.wantEvictions {
+				if evictions[i] != want {
+					t.Errorf("eviction %d: expected %+v, got %+v", i, want, evictions[i])
+				}
+			}
+			if got := svc.Stats().Evictions; got != uint64(len(tt.wantEvictions)) {
+				t.Errorf("expected %d evictions in stats, got %d", len(tt.wantEvictions), got)
+			}
+			if tt.wait > 0 && repo.calls["viewer"] != 2 {
+				t.Errorf("expected expired role to be reloaded, got %d loads", repo.calls["viewer"])
+			}
+		})
+	}
+}
+
+func TestRBACService_TenantEviction(t *testing.T) {
+	var evictions []Eviction
+	repo := &mockTenantPrivilegeRepository{
+		tenants: map[string]map[string]map[string]bool{
+			"acme": {"viewer": {"read:users": true}, "editor": {"write:users": true}},
+		},
+	}
+	svc := NewRBACService(repo, 0, nil,
+		WithTenantCacheLimit(1),
+		WithEvictionCallback(func(e Eviction) { evictions = append(evictions, e) }),
+	)
+	ctx := context.Background()
+
+	for _, roleID := range []string{"viewer", "editor"} {
+		if _, err := svc.GetTenantRolePrivileges(ctx, "acme", roleID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := Eviction{TenantID: "acme", RoleID: "viewer", Reason: EvictedCapacity}
+	if len(evictions) != 1 || evictions[0] != want {
+		t.Errorf("expected %+v, got %v", want, evictions)
+	}
+}
@@ -0,0 +1,219 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Where the privileges of a role came from when a decision was made
+const (
+	SourceCache      = "cache"
+	SourceRepository = "repository"
+)
+
+// Effects of a matched grant
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Decision explains the outcome of a privilege check. It marshals to JSON for
+// display in admin tooling.
+type Decision struct {
+	Allowed     bool   `json:"allowed"`
+	Privilege   string `json:"privilege,omitempty"`
+	Requirement string `json:"requirement,omitempty"`
+	TenantID    string `json:"tenantId,omitempty"`
+
+	// Reason is a human readable summary of the decision
+	Reason string `json:"reason"`
+
+	// FailedRequirement is the most specific part of the requirement that did not hold
+	FailedRequirement string `json:"failedRequirement,omitempty"`
+
+	// Matches lists every grant that matched a checked privilege, denies included
+	Matches []GrantMatch `json:"matches"`
+
+	// Roles describes each evaluated role and where its privileges came from
+	Roles []RoleSource `json:"roles"`
+}
+
+// GrantMatch is a grant that matched a checked privilege
+type GrantMatch struct {
+	Privilege string `json:"privilege"`
+	Grant     string `json:"grant"`
+	Effect    string `json:"effect"`
+	RoleID    string `json:"roleId"`
+
+	// GrantedBy lists the roles holding the grant; with role inheritance these
+	// may be ancestors of RoleID
+	GrantedBy []string `json:"grantedBy"`
+}
+
+// RoleSource describes where the privileges of a role were read from
+type RoleSource struct {
+	RoleID    string     `json:"roleId"`
+	Source    string     `json:"source"`
+	LoadedAt  time.Time  `json:"loadedAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Stale     bool       `json:"stale,omitempty"` // past the stale-after age, see WithStaleWhileRevalidate
+}
+
+// JSON returns the decision as indented JSON
+func (d *Decision) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// explainedRole is a role evaluated for a decision
+type explainedRole struct {
+	roleID  string
+	matcher *privilegeMatcher
+	entry   PrivilegeCacheEntry
+}
+
+// Explain checks a privilege for a role like HasPrivilege and explains the result
+func (s *rbacService) Explain(ctx context.Context, roleID string, privilege string) (*Decision, error) {
+	return s.ExplainRoles(ctx, []string{roleID}, privilege)
+}
+
+// ExplainRoles checks a privilege across several roles like HasPrivilegeInRoles and
+// explains the result
+func (s *rbacService) ExplainRoles(ctx context.Context, roleIDs []string, privilege string) (*Decision, error) {
+
+	decision, roles, err := s.newDecision(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	decision.Privilege = privilege
+
+	allowed, denied := s.collectMatches(decision, roles, privilege)
+	decision.Allowed = allowed && !denied
+
+	switch {
+	case denied:
+		match := firstMatch(decision.Matches, EffectDeny)
+		decision.Reason = fmt.Sprintf("%q is denied by grant %q of role %s", privilege, match.Grant, match.RoleID)
+	case allowed:
+		match := firstMatch(decision.Matches, EffectAllow)
+		decision.Reason = fmt.Sprintf("%q is allowed by grant %q of role %s", privilege, match.Grant, match.RoleID)
+	default:
+		decision.Reason = fmt.Sprintf("no grant of roles [%s] matches %q", strings.Join(roleIDs, ", "), privilege)
+	}
+
+	return decision, nil
+}
+
+// ExplainRequirement checks a compound requirement across several roles like
+// MeetsRequirementInRoles and explains the result
+func (s *rbacService) ExplainRequirement(ctx context.Context, roleIDs []string, req *Requirement) (*Decision, error) {
+
+	decision, roles, err := s.newDecision(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	decision.Requirement = req.String()
+
+	seen := make(map[string]bool)
+	for _, privilege := range req.Privileges() {
+		if !seen[privilege] {
+			seen[privilege] = true
+			s.collectMatches(decision, roles, privilege)
+		}
+	}
+
+	matchers := make(privilegeMatchers, len(roles))
+	for i, role := range roles {
+		matchers[i] = role.matcher
+	}
+
+	decision.Allowed = req.Evaluate(matchers.has)
+	if decision.Allowed {
+		decision.Reason = fmt.Sprintf("requirement %q is met by roles [%s]", decision.Requirement, strings.Join(roleIDs, ", "))
+	} else {
+		decision.FailedRequirement = req.unmet(matchers.has)
+		decision.Reason = fmt.Sprintf("requirement %q is not met: %q does not hold", decision.Requirement, decision.FailedRequirement)
+	}
+
+	return decision, nil
+}
+
+// newDecision loads every role, recording where its privileges came from
+func (s *rbacService) newDecision(ctx context.Context, roleIDs []string) (*Decision, []explainedRole, error) {
+
+	tenantID, _ := GetTenantIDFromContext(ctx)
+
+	decision := &Decision{TenantID: tenantID, Matches: []GrantMatch{}, Roles: []RoleSource{}}
+	roles := make([]explainedRole, 0, len(roleIDs))
+
+	for _, roleID := range roleIDs {
+		_, cached := s.cachedEntry(ctx, tenantID, roleID)
+
+		matcher, err := s.getRoleMatcher(ctx, roleID)
+		if err != nil {
+			return nil, nil, err
+		}
+		entry, _ := s.cachedEntry(ctx, tenantID, roleID)
+
+		source := RoleSource{RoleID: roleID, Source: SourceRepository, LoadedAt: entry.LoadedAt}
+		if cached {
+			source.Source = SourceCache
+			source.Stale = s.isStale(entry.LoadedAt)
+		}
+		if !entry.ExpiresAt.IsZero() {
+			expiresAt := entry.ExpiresAt
+			source.ExpiresAt = &expiresAt
+		}
+
+		decision.Roles = append(decision.Roles, source)
+		roles = append(roles, explainedRole{roleID: roleID, matcher: matcher, entry: entry})
+	}
+
+	return decision, roles, nil
+}
+
+// collectMatches records the grants of every role matching privilege and reports
+// whether any of them allows or denies it
+func (s *rbacService) collectMatches(decision *Decision, roles []explainedRole, privilege string) (allowed bool, denied bool) {
+	for _, role := range roles {
+		if grant, ok := role.matcher.denyingGrant(privilege); ok {
+			denied = true
+			decision.Matches = append(decision.Matches, newGrantMatch(role, privilege, grant, EffectDeny))
+		}
+		if grant, ok := role.matcher.allowingGrant(privilege); ok {
+			allowed = true
+			decision.Matches = append(decision.Matches, newGrantMatch(role, privilege, grant, EffectAllow))
+		}
+	}
+	return allowed, denied
+}
+
+func newGrantMatch(role explainedRole, privilege, grant, effect string) GrantMatch {
+	grantedBy := role.entry.Origins[grant]
+	if len(grantedBy) == 0 {
+		grantedBy = []string{role.roleID}
+	} else {
+		grantedBy = append([]string(nil), grantedBy...)
+		sort.Strings(grantedBy)
+	}
+
+	return GrantMatch{
+		Privilege: privilege,
+		Grant:     grant,
+		Effect:    effect,
+		RoleID:    role.roleID,
+		GrantedBy: grantedBy,
+	}
+}
+
+func firstMatch(matches []GrantMatch, effect string) GrantMatch {
+	for _, match := range matches {
+		if match.Effect == effect {
+			return match
+		}
+	}
+	return GrantMatch{}
+}
@@ -0,0 +1,124 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRBACService_Explain(t *testing.T) {
+	parents := &mockRoleParentRepository{parents: map[string][]string{
+		"contractor": {"employee"},
+	}}
+	svc, _ := newTestService(map[string]map[string]bool{
+		"employee":   {"export:*": true, "read:data": true},
+		"contractor": {"!export:data": true},
+	}, WithRoleParentRepository(parents))
+
+	tests := []struct {
+		name        string
+		roleID      string
+		privilege   string
+		wantAllowed bool
+		wantMatches []GrantMatch
+	}{
+		{
+			name:        "allowed by inherited wildcard",
+			roleID:      "contractor",
+			privilege:   "export:pdf",
+			wantAllowed: true,
+			wantMatches: []GrantMatch{
+				{Privilege: "export:pdf", Grant: "export:*", Effect: EffectAllow, RoleID: "contractor", GrantedBy: []string{"employee"}},
+			},
+		},
+		{
+			name:        "denied by own deny",
+			roleID:      "contractor",
+			privilege:   "export:data",
+			wantAllowed: false,
+			wantMatches: []GrantMatch{
+				{Privilege: "export:data", Grant: "!export:data", Effect: EffectDeny, RoleID: "contractor", GrantedBy: []string{"contractor"}},
+				{Privilege: "export:data", Grant: "export:*", Effect: EffectAllow, RoleID: "contractor", GrantedBy: []string{"employee"}},
+			},
+		},
+		{
+			name:        "no matching grant",
+			roleID:      "employee",
+			privilege:   "delete:data",
+			wantAllowed: false,
+			wantMatches: []GrantMatch{},
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := svc.Explain(context.Background(), tt.roleID, tt.privilege)
+			if err != nil {
+				t.Fatalf("Explain() error = %v", err)
+			}
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("Decision.Allowed = %v, want %v (%s)", decision.Allowed, tt.wantAllowed, decision.Reason)
+			}
+			if !reflect.DeepEqual(decision.Matches, tt.wantMatches) {
+				t.Errorf("Decision.Matches = %+v, want %+v", decision.Matches, tt.wantMatches)
+			}
+		})
+	}
+}
+
+func TestRBACService_Explain_Source(t *testing.T) {
+	svc, _ := newTestService(map[string]map[string]bool{"viewer": {"read:users": true}})
+	ctx := context.Background()
+
+	first, err := svc.Explain(ctx, "viewer", "read:users")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	second, err := svc.Explain(ctx, "viewer", "read:users")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	if got := first.Roles[0].Source; got != SourceRepository {
+		t.Errorf("first Explain() source = %q, want %q", got, SourceRepository)
+	}
+	if got := second.Roles[0].Source; got != SourceCache {
+		t.Errorf("second Explain() source = %q, want %q", got, SourceCache)
+	}
+	if first.Roles[0].LoadedAt.IsZero() || !first.Roles[0].LoadedAt.Equal(second.Roles[0].LoadedAt) {
+		t.Errorf("LoadedAt should be the time the entry was loaded, got %v and %v", first.Roles[0].LoadedAt, second.Roles[0].LoadedAt)
+	}
+}
+
+func TestRBACService_ExplainRequirement(t *testing.T) {
+	svc, _ := newTestService(map[string]map[string]bool{
+		"analyst": {"read:report": true},
+	})
+
+	req := MustParseRequirement("read:report && (export:pdf || export:csv)")
+	decision, err := svc.ExplainRequirement(context.Background(), []string{"analyst"}, req)
+	if err != nil {
+		t.Fatalf("ExplainRequirement() error = %v", err)
+	}
+
+	if decision.Allowed {
+		t.Errorf("Decision.Allowed should be false")
+	}
+	if want := "export:pdf || export:csv"; decision.FailedRequirement != want {
+		t.Errorf("Decision.FailedRequirement = %q, want %q", decision.FailedRequirement, want)
+	}
+
+	data, err := decision.JSON()
+	if err != nil {
+		t.Fatalf("Decision.JSON() error = %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Decision.JSON() is not valid JSON: %v", err)
+	}
+	if decoded["failedRequirement"] != "export:pdf || export:csv" {
+		t.Errorf("JSON failedRequirement = %v", decoded["failedRequirement"])
+	}
+}
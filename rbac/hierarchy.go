@@ -0,0 +1,189 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrRoleCycle is matched by errors.Is for every RoleCycleError
+var ErrRoleCycle = errors.New("rbac: role inheritance cycle")
+
+// RoleCycleError reports a cycle found while resolving role inheritance
+type RoleCycleError struct {
+	// Path lists the roles forming the cycle, starting and ending with the same role
+	Path []string
+}
+
+func (e *RoleCycleError) Error() string {
+	return ErrRoleCycle.Error() + ": " + strings.Join(e.Path, " -> ")
+}
+
+// Is makes errors.Is(err, ErrRoleCycle) work
+func (e *RoleCycleError) Is(target error) bool {
+	return target == ErrRoleCycle
+}
+
+// roleHierarchy remembers which cached roles were built from which ancestors,
+// so a change to a parent can invalidate every role that inherits from it.
+// Roles are tracked per tenant; see tenantRoleKey.
+type roleHierarchy struct {
+	mu         sync.Mutex
+	ancestors  map[string][]string
+	dependents map[string]map[string]bool
+}
+
+// tenantRoleKey identifies a role within a tenant; the global tenant is ""
+func tenantRoleKey(tenantID, roleID string) string {
+	if tenantID == "" {
+		return roleID
+	}
+	return tenantID + "\x00" + roleID
+}
+
+func newRoleHierarchy() *roleHierarchy {
+	return &roleHierarchy{
+		ancestors:  make(map[string][]string),
+		dependents: make(map[string]map[string]bool),
+	}
+}
+
+// track records the ancestors used to build roleID's cached privileges
+func (h *roleHierarchy) track(tenantID, roleID string, ancestors []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.untrackLocked(tenantID, roleID)
+	h.ancestors[tenantRoleKey(tenantID, roleID)] = ancestors
+	for _, ancestor := range ancestors {
+		key := tenantRoleKey(tenantID, ancestor)
+		if h.dependents[key] == nil {
+			h.dependents[key] = make(map[string]bool)
+		}
+		h.dependents[key][roleID] = true
+	}
+}
+
+// untrack forgets roleID's ancestors
+func (h *roleHierarchy) untrack(tenantID, roleID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.untrackLocked(tenantID, roleID)
+}
+
+func (h *roleHierarchy) untrackLocked(tenantID, roleID string) {
+	roleKey := tenantRoleKey(tenantID, roleID)
+	for _, ancestor := range h.ancestors[roleKey] {
+		key := tenantRoleKey(tenantID, ancestor)
+		delete(h.dependents[key], roleID)
+		if len(h.dependents[key]) == 0 {
+			delete(h.dependents, key)
+		}
+	}
+	delete(h.ancestors, roleKey)
+}
+
+// untrackTenant forgets every role of a tenant
+func (h *roleHierarchy) untrackTenant(tenantID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prefix := tenantRoleKey(tenantID, "")
+	for key := range h.ancestors {
+		if tenantID == "" && strings.Contains(key, "\x00") {
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			h.untrackLocked(tenantID, strings.TrimPrefix(key, prefix))
+		}
+	}
+}
+
+// dependentsOf returns every tracked role of the tenant that inherits from roleID
+func (h *roleHierarchy) dependentsOf(tenantID, roleID string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dependents := h.dependents[tenantRoleKey(tenantID, roleID)]
+	roles := make([]string, 0, len(dependents))
+	for role := range dependents {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// resolveAncestors walks the parent graph of roleID depth first and returns
+// all its ancestors (excluding roleID itself). A cycle yields a RoleCycleError.
+// Within a tenant the graph is the tenant's own, see TenantRoleParentRepository.
+func (s *rbacService) resolveAncestors(ctx context.Context, roleID string) ([]string, error) {
+	fetchParents := s.parents.FetchParentRoleIDs
+	if tenantID, _ := GetTenantIDFromContext(ctx); tenantID != "" {
+		tenantParents, ok := s.parents.(TenantRoleParentRepository)
+		if !ok {
+			return nil, fmt.Errorf("rbac: resolving parents of role %s in tenant %s: %w", roleID, tenantID, ErrTenantsNotSupported)
+		}
+		fetchParents = func(ctx context.Context, role string) ([]string, error) {
+			return tenantParents.FetchTenantParentRoleIDs(ctx, tenantID, role)
+		}
+	}
+
+	var (
+		ancestors []string
+		path      []string
+		onPath    = make(map[string]bool)
+		done      = make(map[string]bool)
+		visit     func(role string) error
+	)
+
+	visit = func(role string) error {
+		if onPath[role] {
+			cycle := []string{role}
+			for i := len(path) - 1; i >= 0 && path[i] != role; i-- {
+				cycle = append([]string{path[i]}, cycle...)
+			}
+			return &RoleCycleError{Path: append([]string{role}, cycle...)}
+		}
+		if done[role] {
+			return nil
+		}
+
+		parents, err := fetchParents(ctx, role)
+		if err != nil {
+			return err
+		}
+
+		onPath[role] = true
+		path = append(path, role)
+		for _, parent := range parents {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		onPath[role] = false
+
+		done[role] = true
+		if role != roleID {
+			ancestors = append(ancestors, role)
+		}
+		return nil
+	}
+
+	if err := visit(roleID); err != nil {
+		return nil, err
+	}
+
+	return ancestors, nil
+}
+
+// invalidateDependents drops every cached role of the tenant that inherits from roleID
+func (s *rbacService) invalidateDependents(tenantID, roleID string) {
+	for _, dependent := range s.hierarchy.dependentsOf(tenantID, roleID) {
+		s.logger.Debugf("Invalidating role %s after change to parent role %s", dependent, roleID)
+		s.dropEntry(context.Background(), tenantID, dependent)
+		s.hierarchy.untrack(tenantID, dependent)
+	}
+}
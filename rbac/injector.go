@@ -4,10 +4,44 @@ import (
 	"context"
 )
 
-// InjectContext attaches roleID, userID, and privileges into the given context
+// InjectContext attaches roleID, userID, and privileges into the given context.
+// The privileges are copied; see InjectPrivilegeSet.
 func InjectContext(ctx context.Context, roleID string, userID string, privileges map[string]bool) context.Context {
+	return InjectPrivilegeSet(ctx, roleID, userID, PrivilegeSetFromMap(privileges))
+}
+
+// InjectContextWithRoles attaches roleIDs, userID, and the combined privileges of
+// those roles into the given context. The privileges are copied; see
+// InjectPrivilegeSetWithRoles.
+func InjectContextWithRoles(ctx context.Context, roleIDs []string, userID string, privileges map[string]bool) context.Context {
+	return InjectPrivilegeSetWithRoles(ctx, roleIDs, userID, PrivilegeSetFromMap(privileges))
+}
+
+// InjectPrivilegeSet attaches roleID, userID, and privileges into the given context
+func InjectPrivilegeSet(ctx context.Context, roleID string, userID string, privileges PrivilegeSet) context.Context {
 	ctx = context.WithValue(ctx, RoleIDKey, roleID)
 	ctx = context.WithValue(ctx, UserIDKey, userID)
+	return injectPrivileges(ctx, privileges)
+}
+
+// InjectPrivilegeSetWithRoles attaches roleIDs, userID, and the combined privileges
+// of those roles into the given context
+func InjectPrivilegeSetWithRoles(ctx context.Context, roleIDs []string, userID string, privileges PrivilegeSet) context.Context {
+	roleIDs = append([]string(nil), roleIDs...)
+	ctx = context.WithValue(ctx, RoleIDsKey, roleIDs)
+	ctx = context.WithValue(ctx, UserIDKey, userID)
+	return injectPrivileges(ctx, privileges)
+}
+
+// injectPrivileges stores privileges together with their compiled matcher
+func injectPrivileges(ctx context.Context, privileges PrivilegeSet) context.Context {
 	ctx = context.WithValue(ctx, PrivilegesKey, privileges)
+	ctx = context.WithValue(ctx, privilegeMatcherKey, newPrivilegeMatcher(privileges.codes))
 	return ctx
 }
+
+// InjectTenant attaches tenantID into the given context. Service lookups made with
+// this context only see the roles and privileges of that tenant.
+func InjectTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, TenantIDKey, tenantID)
+}
@@ -0,0 +1,133 @@
+package rbac
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// InvalidationEvent announces that cached privileges changed and must be dropped
+type InvalidationEvent struct {
+	TenantID string // empty outside any tenant
+	// RoleID is the changed role; empty invalidates every role of the tenant
+	RoleID string
+	// Source identifies the publishing service instance, which ignores its own events
+	Source string
+}
+
+// InvalidationBus carries invalidation events between service instances, so a role
+// changed on one instance is dropped from the caches of all others.
+// Implementations must be safe for concurrent use.
+type InvalidationBus interface {
+	Publish(ctx context.Context, event InvalidationEvent) error
+	// Subscribe calls handler for every event published from now on, until
+	// unsubscribe is called
+	Subscribe(handler func(InvalidationEvent)) (unsubscribe func(), err error)
+}
+
+// MemoryInvalidationBus delivers events synchronously to subscribers in the same
+// process. It suits tests and services running several RBACService instances.
+type MemoryInvalidationBus struct {
+	mu       sync.RWMutex
+	handlers map[int]func(InvalidationEvent)
+	nextID   int
+}
+
+// NewMemoryInvalidationBus creates an empty MemoryInvalidationBus
+func NewMemoryInvalidationBus() *MemoryInvalidationBus {
+	return &MemoryInvalidationBus{handlers: make(map[int]func(InvalidationEvent))}
+}
+
+// Publish calls every subscriber with event before returning
+func (b *MemoryInvalidationBus) Publish(ctx context.Context, event InvalidationEvent) error {
+	b.mu.RLock()
+	handlers := make([]func(InvalidationEvent), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return nil
+}
+
+// Subscribe registers handler for every event published from now on
+func (b *MemoryInvalidationBus) Subscribe(handler func(InvalidationEvent)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.handlers, id)
+	}, nil
+}
+
+// newInstanceID returns a random ID telling this service instance's events apart
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// subscribeInvalidations starts applying the bus's events to the local caches
+func (s *rbacService) subscribeInvalidations() {
+	if s.bus == nil {
+		return
+	}
+
+	unsubscribe, err := s.bus.Subscribe(s.handleInvalidation)
+	if err != nil {
+		s.logger.Errorf("Cannot subscribe to invalidation events: %v", err)
+		return
+	}
+	s.unsubscribe = unsubscribe
+}
+
+// handleInvalidation applies an event published by another instance
+func (s *rbacService) handleInvalidation(event InvalidationEvent) {
+	if event.Source == s.instanceID {
+		return
+	}
+
+	ctx := context.Background()
+	if event.RoleID == "" {
+		if err := s.invalidateTenantLocal(ctx, event.TenantID); err != nil {
+			s.logger.Errorf("Error applying invalidation of tenant %s: %v", event.TenantID, err)
+		}
+		return
+	}
+	s.invalidateRoleLocal(ctx, event.TenantID, event.RoleID)
+}
+
+// publishInvalidation tells the other instances to drop a role, or a whole tenant
+// when roleID is empty
+func (s *rbacService) publishInvalidation(ctx context.Context, tenantID, roleID string) error {
+	if s.bus == nil {
+		return nil
+	}
+
+	event := InvalidationEvent{TenantID: tenantID, RoleID: roleID, Source: s.instanceID}
+	if err := s.bus.Publish(ctx, event); err != nil {
+		return fmt.Errorf("rbac: publishing invalidation of role %q in tenant %q: %w", roleID, tenantID, err)
+	}
+	return nil
+}
+
+// invalidateRoleLocal drops a role and every role inheriting from it from the
+// local caches
+func (s *rbacService) invalidateRoleLocal(ctx context.Context, tenantID, roleID string) {
+	s.dropEntry(ctx, tenantID, roleID)
+	s.negative.remove(tenantRoleKey(tenantID, roleID))
+	s.hierarchy.untrack(tenantID, roleID)
+	s.invalidateDependents(tenantID, roleID)
+}
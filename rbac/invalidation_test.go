@@ -0,0 +1,120 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRBACService_InvalidationBus(t *testing.T) {
+	privileges := map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+	}
+
+	tests := []struct {
+		name   string
+		change func(ctx context.Context, svc RBACService) error
+		want   map[string]bool // role ID -> still cached on the other instance
+	}{
+		{
+			name: "set",
+			change: func(ctx context.Context, svc RBACService) error {
+				return svc.SetNewRolePrivileges(ctx, "viewer", []string{"read:posts"})
+			},
+			want: map[string]bool{"viewer": false, "editor": true},
+		},
+		{
+			name: "delete",
+			change: func(ctx context.Context, svc RBACService) error {
+				return svc.DeleteRolePrivileges(ctx, "editor")
+			},
+			want: map[string]bool{"viewer": true, "editor": false},
+		},
+		{
+			name: "tenant",
+			change: func(ctx context.Context, svc RBACService) error {
+				return svc.InvalidateTenant(ctx, "")
+			},
+			want: map[string]bool{"viewer": false, "editor": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewMemoryInvalidationBus()
+			first, _ := newTestService(privileges, WithInvalidationBus(bus))
+			second, _ := newTestService(privileges, WithInvalidationBus(bus))
+			ctx := context.Background()
+
+			for _, svc := range []*rbacService{first, second} {
+				for roleID := range privileges {
+					if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				}
+			}
+
+			if err := tt.change(ctx, first); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for roleID, want := range tt.want {
+				if _, cached := second.localCache().Get(roleID); cached != want {
+					t.Errorf("role %s: expected cached %v on the other instance, got %v", roleID, want, cached)
+				}
+			}
+		})
+	}
+}
+
+func TestRBACService_InvalidationBus_IgnoresOwnEvents(t *testing.T) {
+	bus := NewMemoryInvalidationBus()
+	svc, _ := newTestService(nil, WithInvalidationBus(bus))
+	ctx := context.Background()
+
+	if err := svc.SetNewRolePrivileges(ctx, "viewer", []string{"read:users"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, cached := svc.localCache().Get("viewer"); !cached {
+		t.Error("expected the publishing instance to keep its own change")
+	}
+}
+
+func TestRBACService_InvalidationBus_Close(t *testing.T) {
+	bus := NewMemoryInvalidationBus()
+	first, _ := newTestService(nil, WithInvalidationBus(bus))
+	second, _ := newTestService(nil, WithInvalidationBus(bus))
+	ctx := context.Background()
+
+	if err := second.SetNewRolePrivileges(ctx, "viewer", []string{"read:users"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := first.DeleteRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, cached := second.localCache().Get("viewer"); !cached {
+		t.Error("expected a closed instance to stop receiving events")
+	}
+}
+
+type failingInvalidationBus struct {
+	*MemoryInvalidationBus
+}
+
+func (b *failingInvalidationBus) Publish(ctx context.Context, event InvalidationEvent) error {
+	return errors.New("bus down")
+}
+
+func TestRBACService_InvalidationBus_PublishError(t *testing.T) {
+	bus := &failingInvalidationBus{MemoryInvalidationBus: NewMemoryInvalidationBus()}
+	svc, _ := newTestService(nil, WithInvalidationBus(bus))
+
+	err := svc.DeleteRolePrivileges(context.Background(), "viewer")
+	if err == nil {
+		t.Fatal("expected the publish error to be returned")
+	}
+}
@@ -0,0 +1,118 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrServiceClosed is returned by Start after Close
+var ErrServiceClosed = errors.New("rbac: service closed")
+
+// refresherLifecycle tracks the background refresher goroutine and the other
+// background work Close waits for
+type refresherLifecycle struct {
+	mu         sync.Mutex
+	cancel     context.CancelFunc
+	done       chan struct{}
+	closed     bool
+	background sync.WaitGroup
+}
+
+// Start runs the periodic refresher in the background. It does nothing when the
+// refresh interval is not positive or the refresher is already running.
+func (s *rbacService) Start() error {
+	s.lifecycle.mu.Lock()
+	defer s.lifecycle.mu.Unlock()
+
+	if s.lifecycle.closed {
+		return ErrServiceClosed
+	}
+	if s.refreshInterval <= 0 || s.lifecycle.done != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.lifecycle.cancel = cancel
+	s.lifecycle.done = done
+
+	go func() {
+		defer close(done)
+		s.startPeriodicRefresh(ctx, s.refreshInterval)
+	}()
+
+	return nil
+}
+
+// Stop cancels the periodic refresher, including a refresh in flight, and waits
+// for it to exit or for ctx to end. The refresher can be started again afterwards.
+func (s *rbacService) Stop(ctx context.Context) error {
+	s.lifecycle.mu.Lock()
+	cancel, done := s.lifecycle.cancel, s.lifecycle.done
+	s.lifecycle.cancel, s.lifecycle.done = nil, nil
+	s.lifecycle.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the refresher and all scheduled invalidations for good, and waits for
+// background reloads in flight to finish
+func (s *rbacService) Close() error {
+	s.lifecycle.mu.Lock()
+	s.lifecycle.closed = true
+	unsubscribe := s.unsubscribe
+	s.unsubscribe = nil
+	s.lifecycle.mu.Unlock()
+
+	err := s.Stop(context.Background())
+
+	s.timersMu.Lock()
+	for key, timer := range s.timers {
+		timer.Stop()
+		delete(s.timers, key)
+	}
+	s.timersMu.Unlock()
+
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+
+	s.lifecycle.background.Wait()
+	return err
+}
+
+// goBackground runs fn on a goroutine Close waits for. It reports false, without
+// running fn, once the service is closed.
+func (s *rbacService) goBackground(fn func()) bool {
+	s.lifecycle.mu.Lock()
+	defer s.lifecycle.mu.Unlock()
+
+	if s.lifecycle.closed {
+		return false
+	}
+	s.lifecycle.background.Add(1)
+	go func() {
+		defer s.lifecycle.background.Done()
+		fn()
+	}()
+	return true
+}
+
+// isClosed reports whether Close was called
+func (s *rbacService) isClosed() bool {
+	s.lifecycle.mu.Lock()
+	defer s.lifecycle.mu.Unlock()
+
+	return s.lifecycle.closed
+}
@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingPrivilegeRepository blocks every fetch until its context ends
+type blockingPrivilegeRepository struct {
+	started   chan struct{}
+	cancelled atomic.Bool
+}
+
+func (b *blockingPrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	b.cancelled.Store(true)
+	return nil, ctx.Err()
+}
+
+func TestRBACService_Stop_CancelsInFlightRefresh(t *testing.T) {
+	repo := &blockingPrivilegeRepository{started: make(chan struct{}, 1)}
+	svc := NewRBACService(repo, 10*time.Millisecond, nil).(*rbacService)
+	svc.SetNewRolePrivileges(context.Background(), "viewer", []string{"read:users"})
+
+	select {
+	case <-repo.started:
+	case <-time.After(time.Second):
+		t.Fatalf("refresher did not start")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := svc.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if !repo.cancelled.Load() {
+		t.Errorf("Stop() should cancel the refresh in flight")
+	}
+}
+
+func TestRBACService_ManualStart(t *testing.T) {
+	repo := &mockPrivilegeRepository{privileges: map[string]map[string]bool{"viewer": {"read:users": true}}}
+	svc := NewRBACService(repo, time.Millisecond, nil, WithManualStart()).(*rbacService)
+	ctx := context.Background()
+
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("GetRolePrivileges() error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := svc.lifecycle.done; got != nil {
+		t.Fatalf("refresher should not run before Start()")
+	}
+
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := svc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := svc.Start(); !errors.Is(err, ErrServiceClosed) {
+		t.Errorf("Start() after Close() error = %v, want %v", err, ErrServiceClosed)
+	}
+}
@@ -0,0 +1,202 @@
+package rbac
+
+import "strings"
+
+const (
+	// PrivilegeSeparator separates the segments of a privilege code, e.g. "read:users"
+	PrivilegeSeparator = ":"
+
+	// Wildcard matches any single segment of a privilege code. As the last segment
+	// of a grant it matches all remaining segments, so "*" alone grants everything.
+	Wildcard = "*"
+
+	// DenyPrefix marks a grant as an explicit deny, e.g. "!export:data".
+	// A deny overrides any allow for the privileges it matches, wildcards included.
+	DenyPrefix = "!"
+)
+
+// privilegeMatcher answers privilege checks against a set of grants. Exact grants
+// are plain map lookups; wildcard grants are compiled into a segment trie so a
+// check never scans the full grant list.
+type privilegeMatcher struct {
+	exact        map[string]bool
+	patterns     *patternNode    // nil when there are no wildcard grants
+	deny         map[string]bool // nil when there are no denies
+	denyPatterns *patternNode
+}
+
+type patternNode struct {
+	children map[string]*patternNode
+	wildcard *patternNode
+	terminal string // the grant ending at this node, if any
+	rest     string // the grant ending in "*" at this node, if any
+}
+
+// newPrivilegeMatcher compiles the granted entries of privileges
+func newPrivilegeMatcher(privileges map[string]bool) *privilegeMatcher {
+	m := &privilegeMatcher{exact: privileges}
+
+	for code, granted := range privileges {
+		if !granted {
+			continue
+		}
+
+		if denied, ok := strings.CutPrefix(code, DenyPrefix); ok {
+			if m.deny == nil {
+				m.deny = make(map[string]bool)
+			}
+			m.deny[denied] = true
+			if isWildcardGrant(denied) {
+				if m.denyPatterns == nil {
+					m.denyPatterns = &patternNode{}
+				}
+				m.denyPatterns.insert(denied, code)
+			}
+			continue
+		}
+
+		if isWildcardGrant(code) {
+			if m.patterns == nil {
+				m.patterns = &patternNode{}
+			}
+			m.patterns.insert(code, code)
+		}
+	}
+
+	return m
+}
+
+// has reports whether privilege is allowed and not explicitly denied
+func (m *privilegeMatcher) has(privilege string) bool {
+	return m.allows(privilege) && !m.denies(privilege)
+}
+
+// allows reports whether privilege is granted exactly or by a wildcard grant,
+// ignoring denies
+func (m *privilegeMatcher) allows(privilege string) bool {
+	_, ok := m.allowingGrant(privilege)
+	return ok
+}
+
+// denies reports whether privilege is explicitly denied
+func (m *privilegeMatcher) denies(privilege string) bool {
+	_, ok := m.denyingGrant(privilege)
+	return ok
+}
+
+// allowingGrant returns the grant that allows privilege, ignoring denies. A
+// privilege with DenyPrefix names a deny, not something a role can be allowed, so
+// neither the deny grant itself nor a wildcard allows it.
+func (m *privilegeMatcher) allowingGrant(privilege string) (string, bool) {
+	if strings.HasPrefix(privilege, DenyPrefix) {
+		return "", false
+	}
+	if m.exact[privilege] {
+		return privilege, true
+	}
+	if m.patterns == nil {
+		return "", false
+	}
+	return m.patterns.match(strings.Split(privilege, PrivilegeSeparator))
+}
+
+// denyingGrant returns the deny grant (with its DenyPrefix) that denies privilege
+func (m *privilegeMatcher) denyingGrant(privilege string) (string, bool) {
+	if m.deny == nil {
+		return "", false
+	}
+	if m.deny[privilege] {
+		return DenyPrefix + privilege, true
+	}
+	if m.denyPatterns == nil {
+		return "", false
+	}
+	return m.denyPatterns.match(strings.Split(privilege, PrivilegeSeparator))
+}
+
+// privilegeMatchers combines the matchers of several roles with deny-overrides
+// semantics: a deny in any role wins over an allow in any other role
+type privilegeMatchers []*privilegeMatcher
+
+func (ms privilegeMatchers) has(privilege string) bool {
+	allowed := false
+	for _, m := range ms {
+		if m.denies(privilege) {
+			return false
+		}
+		allowed = allowed || m.allows(privilege)
+	}
+	return allowed
+}
+
+// insert adds pattern to the trie, remembering grant as the grant it came from
+func (n *patternNode) insert(pattern, grant string) {
+	segments := strings.Split(pattern, PrivilegeSeparator)
+
+	node := n
+	for i, segment := range segments {
+		if segment == Wildcard {
+			if node.wildcard == nil {
+				node.wildcard = &patternNode{}
+			}
+			node = node.wildcard
+			if i == len(segments)-1 {
+				node.rest = grant
+				return
+			}
+			continue
+		}
+
+		if node.children == nil {
+			node.children = make(map[string]*patternNode)
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			child = &patternNode{}
+			node.children[segment] = child
+		}
+		node = child
+	}
+	node.terminal = grant
+}
+
+// match returns the grant matching segments, if any
+func (n *patternNode) match(segments []string) (string, bool) {
+	if len(segments) == 0 {
+		return n.terminal, n.terminal != ""
+	}
+
+	if n.wildcard != nil {
+		if n.wildcard.rest != "" {
+			return n.wildcard.rest, true
+		}
+		if grant, ok := n.wildcard.match(segments[1:]); ok {
+			return grant, true
+		}
+	}
+
+	child, ok := n.children[segments[0]]
+	if !ok {
+		return "", false
+	}
+	return child.match(segments[1:])
+}
+
+// isWildcardGrant reports whether code contains a whole "*" segment
+func isWildcardGrant(code string) bool {
+	if !strings.Contains(code, Wildcard) {
+		return false
+	}
+	for _, segment := range strings.Split(code, PrivilegeSeparator) {
+		if segment == Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPrivilege reports whether a single allow grant covers privilege, using the
+// same segment-aware wildcard rules as the service
+func MatchPrivilege(grant string, privilege string) bool {
+	return newPrivilegeMatcher(map[string]bool{grant: true}).has(privilege)
+}
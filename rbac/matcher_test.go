@@ -0,0 +1,75 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrivilegeMatcher_Has(t *testing.T) {
+	tests := []struct {
+		name      string
+		grants    map[string]bool
+		privilege string
+		want      bool
+	}{
+		{name: "exact grant", grants: map[string]bool{"users:read": true}, privilege: "users:read", want: true},
+		{name: "missing grant", grants: map[string]bool{"users:read": true}, privilege: "users:write", want: false},
+		{name: "false is not a grant", grants: map[string]bool{"users:read": false}, privilege: "users:read", want: false},
+		{name: "trailing wildcard", grants: map[string]bool{"users:*": true}, privilege: "users:delete", want: true},
+		{name: "trailing wildcard spans segments", grants: map[string]bool{"users:*": true}, privilege: "users:read:own", want: true},
+		{name: "trailing wildcard needs a segment", grants: map[string]bool{"users:*": true}, privilege: "users", want: false},
+		{name: "trailing wildcard other prefix", grants: map[string]bool{"users:*": true}, privilege: "reports:read", want: false},
+		{name: "leading wildcard", grants: map[string]bool{"*:read": true}, privilege: "reports:read", want: true},
+		{name: "leading wildcard other action", grants: map[string]bool{"*:read": true}, privilege: "reports:write", want: false},
+		{name: "leading wildcard is one segment", grants: map[string]bool{"*:read": true}, privilege: "a:b:read", want: false},
+		{name: "middle wildcard", grants: map[string]bool{"users:*:own": true}, privilege: "users:read:own", want: true},
+		{name: "global wildcard", grants: map[string]bool{"*": true}, privilege: "anything:at:all", want: true},
+		{name: "glob inside segment is literal", grants: map[string]bool{"users:re*": true}, privilege: "users:read", want: false},
+		{name: "backtracks across branches", grants: map[string]bool{"users:*:own": true, "*:read:all": true}, privilege: "users:read:all", want: true},
+		{name: "deny overrides exact allow", grants: map[string]bool{"export:data": true, "!export:data": true}, privilege: "export:data", want: false},
+		{name: "deny overrides wildcard allow", grants: map[string]bool{"*": true, "!export:data": true}, privilege: "export:data", want: false},
+		{name: "wildcard deny", grants: map[string]bool{"export:*": true, "!export:*": true}, privilege: "export:pdf", want: false},
+		{name: "deny leaves other privileges", grants: map[string]bool{"*": true, "!export:data": true}, privilege: "export:pdf", want: true},
+		{name: "deny alone grants nothing", grants: map[string]bool{"!export:data": true}, privilege: "export:data", want: false},
+		{name: "deny is not an allow of itself", grants: map[string]bool{"!export:data": true}, privilege: "!export:data", want: false},
+		{name: "global wildcard does not allow a deny", grants: map[string]bool{"*": true}, privilege: "!export:data", want: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPrivilegeMatcher(tt.grants)
+			if got := m.has(tt.privilege); got != tt.want {
+				t.Errorf("privilegeMatcher.has(%q) got = %v, want %v", tt.privilege, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasPrivilegeInContext_Wildcard(t *testing.T) {
+	ctx := InjectContext(context.Background(), "admin", "u1", map[string]bool{"users:*": true})
+
+	if !HasPrivilegeInContext(ctx, "users:write") {
+		t.Errorf("HasPrivilegeInContext() should honor wildcard grants")
+	}
+	if HasPrivilegeInContext(ctx, "reports:write") {
+		t.Errorf("HasPrivilegeInContext() should not grant unrelated privileges")
+	}
+}
+
+func TestRBACService_HasPrivilege_DenyArgument(t *testing.T) {
+	svc, _ := newTestService(map[string]map[string]bool{
+		"contractor": {"read:data": true, "!export:data": true},
+		"root":       {"*": true},
+	}, WithCompiledPrivileges())
+	ctx := context.Background()
+
+	for _, roleID := range []string{"contractor", "root"} {
+		if ok, err := svc.HasPrivilege(ctx, roleID, "!export:data"); err != nil || ok {
+			t.Errorf("HasPrivilege(%s, !export:data) got = %v, %v, want false", roleID, ok, err)
+		}
+		if ok, err := svc.HasPrivilegeHandle(ctx, roleID, ResolvePrivilege("!export:data")); err != nil || ok {
+			t.Errorf("HasPrivilegeHandle(%s, !export:data) got = %v, %v, want false", roleID, ok, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package rbac
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxNegativeEntries bounds the negative cache by default, since role IDs taken
+// from requests feed it
+const maxNegativeEntries = 10000
+
+// negativeCache remembers roles the repository reported as not found, so a bad
+// role ID does not reach the repository on every request. Entries share one TTL, so
+// they expire in the order they were added; adding one drops the expired ones and,
+// beyond the limit, the oldest.
+type negativeCache struct {
+	mu      sync.Mutex
+	limit   int                      // 0 means maxNegativeEntries
+	order   *list.List               // of *negativeEntry, front expires first
+	entries map[string]*list.Element // tenantRoleKey -> element in order
+}
+
+type negativeEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// add remembers key as not found until expiresAt
+func (n *negativeCache) add(key string, expiresAt time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.entries == nil {
+		n.order = list.New()
+		n.entries = make(map[string]*list.Element)
+	}
+	if element, ok := n.entries[key]; ok {
+		n.order.Remove(element)
+	}
+	n.entries[key] = n.order.PushBack(&negativeEntry{key: key, expiresAt: expiresAt})
+
+	limit := n.limit
+	if limit <= 0 {
+		limit = maxNegativeEntries
+	}
+	now := time.Now()
+	for front := n.order.Front(); front != nil; front = n.order.Front() {
+		entry := front.Value.(*negativeEntry)
+		if n.order.Len() <= limit && now.Before(entry.expiresAt) {
+			break
+		}
+		n.order.Remove(front)
+		delete(n.entries, entry.key)
+	}
+}
+
+// contains reports whether key is known as not found, dropping it once expired
+func (n *negativeCache) contains(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	element, ok := n.entries[key]
+	if !ok {
+		return false
+	}
+	if !time.Now().Before(element.Value.(*negativeEntry).expiresAt) {
+		n.order.Remove(element)
+		delete(n.entries, key)
+		return false
+	}
+	return true
+}
+
+// remove forgets key
+func (n *negativeCache) remove(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if element, ok := n.entries[key]; ok {
+		n.order.Remove(element)
+		delete(n.entries, key)
+	}
+}
+
+// removeTenant forgets every key of a tenant
+func (n *negativeCache) removeTenant(tenantID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	prefix := tenantRoleKey(tenantID, "")
+	for key, element := range n.entries {
+		if tenantID == "" && strings.Contains(key, "\x00") {
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			n.order.Remove(element)
+			delete(n.entries, key)
+		}
+	}
+}
+
+// len returns the number of remembered keys, expired ones not yet dropped included
+func (n *negativeCache) len() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.entries)
+}
+
+// handleLoadError records a failed load of roleID. When the repository reports the
+// role as not found, any stale entry is dropped, the loss of its privileges is
+// reported and, with a negative TTL configured, the role is remembered as not found.
+func (s *rbacService) handleLoadError(tenantID, roleID string, err error) error {
+	if !isRoleNotFound(err) {
+		s.stats.loadErrors.Add(1)
+		return err
+	}
+
+	s.stats.notFound.Add(1)
+	s.dropEntry(context.Background(), tenantID, roleID)
+	s.hierarchy.untrack(tenantID, roleID)
+	s.invalidateDependents(tenantID, roleID)
+	s.roleRemoved(tenantID, roleID)
+
+	if s.negativeTTL > 0 {
+		s.negative.add(tenantRoleKey(tenantID, roleID), time.Now().Add(s.negativeTTL))
+	}
+
+	return err
+}
@@ -0,0 +1,184 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRBACService_NegativeCache(t *testing.T) {
+	tests := []struct {
+		name          string
+		ttl           time.Duration
+		lookups       int
+		wantRepoCalls int
+		wantNegHits   uint64
+	}{
+		{name: "disabled", ttl: 0, lookups: 3, wantRepoCalls: 3, wantNegHits: 0},
+		{name: "enabled", ttl: time.Minute, lookups: 3, wantRepoCalls: 1, wantNegHits: 2},
+		{name: "expired", ttl: time.Nanosecond, lookups: 2, wantRepoCalls: 2, wantNegHits: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := newTestService(nil, WithNegativeCacheTTL(tt.ttl))
+			repo.missing = map[string]bool{"ghost": true}
+
+			for i := 0; i < tt.lookups; i++ {
+				if tt.ttl == time.Nanosecond {
+					time.Sleep(time.Millisecond)
+				}
+				_, err := svc.GetRolePrivileges(context.Background(), "ghost")
+				if !errors.Is(err, ErrRoleNotFound) {
+					t.Fatalf("lookup %d: expected ErrRoleNotFound, got %v", i, err)
+				}
+				var notFound *RoleNotFoundError
+				if !errors.As(err, &notFound) || notFound.RoleID != "ghost" {
+					t.Fatalf("lookup %d: expected RoleNotFoundError for ghost, got %v", i, err)
+				}
+			}
+
+			if repo.calls["ghost"] != tt.wantRepoCalls {
+				t.Errorf("expected %d repository calls, got %d", tt.wantRepoCalls, repo.calls["ghost"])
+			}
+			stats := svc.Stats()
+			if stats.NegativeHits != tt.wantNegHits {
+				t.Errorf("expected %d negative hits, got %d", tt.wantNegHits, stats.NegativeHits)
+			}
+			if stats.NotFound != uint64(tt.wantRepoCalls) {
+				t.Errorf("expected %d not-found loads, got %d", tt.wantRepoCalls, stats.NotFound)
+			}
+			if stats.LoadErrors != 0 {
+				t.Errorf("expected no load errors, got %d", stats.LoadErrors)
+			}
+		})
+	}
+}
+
+func TestRBACService_NegativeCache_Bounded(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		wait  time.Duration
+		limit int
+		want  []string // roles still remembered
+	}{
+		{name: "expired dropped on add", ttl: 5 * time.Millisecond, wait: 20 * time.Millisecond, want: []string{"ghost-3"}},
+		{name: "oldest dropped beyond limit", ttl: time.Minute, limit: 2, want: []string{"ghost-2", "ghost-3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := newTestService(nil, WithNegativeCacheTTL(tt.ttl))
+			svc.negative.limit = tt.limit
+			repo.missing = map[string]bool{"ghost-1": true, "ghost-2": true, "ghost-3": true}
+			ctx := context.Background()
+
+			for _, roleID := range []string{"ghost-1", "ghost-2", "ghost-3"} {
+				if roleID == "ghost-3" {
+					time.Sleep(tt.wait)
+				}
+				if _, err := svc.GetRolePrivileges(ctx, roleID); !errors.Is(err, ErrRoleNotFound) {
+					t.Fatalf("expected ErrRoleNotFound, got %v", err)
+				}
+			}
+
+			if n := svc.Stats().NegativeEntries; n != len(tt.want) {
+				t.Errorf("expected %d negative entries, got %d", len(tt.want), n)
+			}
+			for _, roleID := range tt.want {
+				if !svc.negative.contains(tenantRoleKey("", roleID)) {
+					t.Errorf("expected %s to be remembered", roleID)
+				}
+			}
+		})
+	}
+}
+
+func TestRBACService_NegativeCache_ClearedBySet(t *testing.T) {
+	svc, repo := newTestService(nil, WithNegativeCacheTTL(time.Minute))
+	repo.missing = map[string]bool{"ghost": true}
+	ctx := context.Background()
+
+	if _, err := svc.GetRolePrivileges(ctx, "ghost"); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("expected ErrRoleNotFound, got %v", err)
+	}
+	if err := svc.SetNewRolePrivileges(ctx, "ghost", []string{"read:users"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ok, err := svc.HasPrivilege(ctx, "ghost", "read:users")
+	if err != nil || !ok {
+		t.Errorf("expected privilege after Set, got %v, %v", ok, err)
+	}
+	if n := svc.Stats().NegativeEntries; n != 0 {
+		t.Errorf("expected no negative entries, got %d", n)
+	}
+}
+
+func TestRBACService_NegativeCache_RemovedRole(t *testing.T) {
+	svc, repo := newTestService(map[string]map[string]bool{
+		"editor": {"write:users": true},
+	}, WithNegativeCacheTTL(time.Minute))
+	ctx := context.Background()
+
+	if _, err := svc.GetRolePrivileges(ctx, "editor"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	repo.missing = map[string]bool{"editor": true}
+	svc.refreshCache(ctx, []string{"editor"})
+
+	if _, ok := svc.localCache().Get("editor"); ok {
+		t.Error("expected removed role to be dropped from the cache")
+	}
+	if _, err := svc.GetRolePrivileges(ctx, "editor"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("expected ErrRoleNotFound, got %v", err)
+	}
+}
+
+func TestRBACService_NegativeCache_MissingParent(t *testing.T) {
+	svc, repo := newTestService(map[string]map[string]bool{
+		"editor": {"write:users": true},
+	}, WithNegativeCacheTTL(time.Minute), WithRoleParentRepository(&mockRoleParentRepository{
+		parents: map[string][]string{"editor": {"ghost"}},
+	}))
+	repo.missing = map[string]bool{"ghost": true}
+
+	_, err := svc.GetRolePrivileges(context.Background(), "editor")
+	if !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("expected wrapped ErrRoleNotFound, got %v", err)
+	}
+	stats := svc.Stats()
+	if stats.NegativeEntries != 0 || stats.NotFound != 0 {
+		t.Errorf("expected a missing parent not to mark the role as not found, got %+v", stats)
+	}
+}
+
+func TestRBACService_Stats(t *testing.T) {
+	svc, repo := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	repo.err = errors.New("db down")
+	if _, err := svc.GetRolePrivileges(ctx, "admin"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	got := svc.Stats()
+	if got.OldestEntryAge <= 0 {
+		t.Errorf("expected the age of the cached role, got %v", got.OldestEntryAge)
+	}
+	got.OldestEntryAge = 0
+	want := Stats{Hits: 2, Misses: 2, Loads: 2, LoadErrors: 1, Entries: 1}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,162 @@
+package rbac
+
+import "time"
+
+// Option configures optional behavior of the RBAC service
+type Option func(*rbacService)
+
+// WithUserRoleRepository sets the repository used to resolve a user's roles
+func WithUserRoleRepository(repo UserRoleRepository) Option {
+	return func(s *rbacService) {
+		s.userRoles = repo
+	}
+}
+
+// WithRoleParentRepository enables role inheritance. A role's effective privileges
+// become the union of its own privileges and those of all its ancestors. Lookups
+// within a tenant need repo to implement TenantRoleParentRepository.
+func WithRoleParentRepository(repo RoleParentRepository) Option {
+	return func(s *rbacService) {
+		s.parents = repo
+	}
+}
+
+// WithPrivilegeWriter persists the changes made with SetNewRolePrivileges and
+// DeleteRolePrivileges through writer before they reach the cache. Those methods
+// then fail for roles of a tenant.
+func WithPrivilegeWriter(writer PrivilegeWriter) Option {
+	return func(s *rbacService) {
+		s.writer = writer
+	}
+}
+
+// WithTenantCacheLimit bounds the number of roles cached per tenant. Beyond it the
+// least recently used role of that tenant is evicted. 0 means unbounded.
+func WithTenantCacheLimit(maxEntriesPerTenant int) Option {
+	return func(s *rbacService) {
+		s.tenantCacheLimit = maxEntriesPerTenant
+	}
+}
+
+// WithManualStart creates the service without starting the periodic refresher.
+// Call Start and Stop to run it as part of your application's lifecycle.
+func WithManualStart() Option {
+	return func(s *rbacService) {
+		s.manualStart = true
+	}
+}
+
+// WithNegativeCacheTTL remembers roles the repository reports as not found
+// (ErrRoleNotFound) for ttl, answering lookups for them without a repository call.
+// At most 10000 roles are remembered, the oldest forgotten first. 0 disables
+// negative caching.
+func WithNegativeCacheTTL(ttl time.Duration) Option {
+	return func(s *rbacService) {
+		s.negativeTTL = ttl
+	}
+}
+
+// WithCacheMaxEntries bounds the number of roles cached outside any tenant. Beyond it
+// the least recently used role is evicted. 0 means unbounded.
+func WithCacheMaxEntries(maxEntries int) Option {
+	return func(s *rbacService) {
+		s.cacheMaxEntries = maxEntries
+	}
+}
+
+// WithCacheTTL expires every cached role ttl after it was loaded, tenants included.
+// An expired role is loaded again on its next use. Expired entries are dropped by
+// the refresh and, at most once per ttl, when another role is stored. 0 means
+// entries never expire.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(s *rbacService) {
+		s.cacheTTL = ttl
+	}
+}
+
+// WithEvictionCallback calls fn for every role the caches drop on their own, because
+// they are full or the entry expired. Explicit deletes and invalidations are not
+// reported. fn runs synchronously and must not block.
+func WithEvictionCallback(fn func(Eviction)) Option {
+	return func(s *rbacService) {
+		s.onEvict = fn
+	}
+}
+
+// WithStaleWhileRevalidate reloads a cached role in the background once it is older
+// than staleAfter, serving the cached privileges meanwhile. While reloads fail the
+// stale entry keeps being served until it is maxAge old; after that the role is
+// loaded like any other miss. A maxAge of 0 serves stale entries until a reload
+// succeeds. WithCacheTTL, when shorter, still bounds the age of every entry.
+func WithStaleWhileRevalidate(staleAfter, maxAge time.Duration) Option {
+	return func(s *rbacService) {
+		s.staleAfter = staleAfter
+		s.maxStaleAge = maxAge
+	}
+}
+
+// WithRefreshConcurrency lets the periodic refresh reload up to n roles (or batches
+// of roles, see BatchPrivilegeRepository) at the same time. The repository must be
+// safe for concurrent use. Defaults to 1.
+func WithRefreshConcurrency(n int) Option {
+	return func(s *rbacService) {
+		s.refreshConcurrency = n
+	}
+}
+
+// WithRefreshBatchSize sets how many roles the periodic refresh fetches per call to
+// a BatchPrivilegeRepository. Defaults to DefaultRefreshBatchSize.
+func WithRefreshBatchSize(n int) Option {
+	return func(s *rbacService) {
+		s.refreshBatchSize = n
+	}
+}
+
+// WithRefreshStreamingThreshold makes the periodic refresh read every role in one pass
+// over a StreamingPrivilegeRepository once at least n roles are cached, instead of
+// fetching them in batches or one by one. The pass reads all roles in the repository,
+// so set n close to their number: it pays off when most of them are cached. A negative
+// n never streams. By default only a repository without BatchPrivilegeRepository is
+// streamed, whenever more than one role is cached.
+func WithRefreshStreamingThreshold(n int) Option {
+	return func(s *rbacService) {
+		s.refreshStreamThreshold = n
+	}
+}
+
+// WithRefreshJitter delays every periodic refresh by a random duration up to jitter,
+// so services started together do not all hit the repository at the same moment.
+func WithRefreshJitter(jitter time.Duration) Option {
+	return func(s *rbacService) {
+		s.refreshJitter = jitter
+	}
+}
+
+// WithCompiledPrivileges compiles every cached role into a bitset as it is stored,
+// and shares one copy of each privilege code string between all roles. Checks with
+// HasPrivilegeHandle then never compile on the request path. Without it bitsets
+// are compiled on a role's first handle check.
+func WithCompiledPrivileges() Option {
+	return func(s *rbacService) {
+		s.compiledPrivileges = true
+	}
+}
+
+// WithPrivilegeCache replaces the in-process cache of roles outside any tenant, e.g.
+// with a backend shared by many instances of the service. WithCacheMaxEntries and
+// WithEvictionCallback do not apply to it; tenant caches stay in-process.
+func WithPrivilegeCache(cache PrivilegeCache) Option {
+	return func(s *rbacService) {
+		s.cache = cache
+	}
+}
+
+// WithInvalidationBus shares invalidations with other instances of the service:
+// SetNewRolePrivileges, DeleteRolePrivileges, InvalidateTenantRole and InvalidateTenant
+// publish an event on bus, and events from other instances drop roles from the
+// local caches. The subscription ends with Close.
+func WithInvalidationBus(bus InvalidationBus) Option {
+	return func(s *rbacService) {
+		s.bus = bus
+	}
+}
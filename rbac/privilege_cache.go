@@ -0,0 +1,131 @@
+package rbac
+
+import (
+	"context"
+	"time"
+)
+
+// PrivilegeCacheEntry is the cached state of one role
+type PrivilegeCacheEntry struct {
+	Privileges PrivilegeSet
+	// LoadedAt is when the privileges were read from the repository or set
+	LoadedAt time.Time
+	// ExpiresAt is when the entry stops being valid; zero means never. A backend
+	// should stop returning the entry by then, the service ignores it anyway.
+	ExpiresAt time.Time
+	// Origins maps each grant to the roles it was inherited from, when role
+	// inheritance is enabled
+	Origins map[string][]string
+}
+
+// expired reports whether the entry is no longer valid at now
+func (e PrivilegeCacheEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// CacheStats is a snapshot of a PrivilegeCache's own metrics
+type CacheStats struct {
+	Entries int    // cached roles; -1 when the backend cannot tell cheaply
+	Hits    uint64 // GetEntry calls that found the role
+	Misses  uint64 // GetEntry calls that did not
+	Errors  uint64 // failed backend operations
+}
+
+// PrivilegeCache stores the privileges of roles between repository loads. The
+// service uses RolePrivilegesCache unless WithPrivilegeCache provides another
+// implementation, e.g. one shared by many instances of the service. Implementations
+// must be safe for concurrent use.
+type PrivilegeCache interface {
+	GetEntry(ctx context.Context, roleID string) (PrivilegeCacheEntry, bool, error)
+	SetEntry(ctx context.Context, roleID string, entry PrivilegeCacheEntry) error
+	DeleteEntry(ctx context.Context, roleID string) error
+	Clear(ctx context.Context) error
+	Keys(ctx context.Context) ([]string, error)
+	Stats() CacheStats
+}
+
+// cachedEntry reads the entry of a role from the cache of a tenant. Expired
+// entries and cache errors count as misses.
+func (s *rbacService) cachedEntry(ctx context.Context, tenantID, roleID string) (PrivilegeCacheEntry, bool) {
+	cache, ok := s.existingCacheFor(tenantID)
+	if !ok {
+		return PrivilegeCacheEntry{}, false
+	}
+	entry, exist, err := cache.GetEntry(ctx, roleID)
+	if err != nil {
+		s.logger.Errorf("Error reading role %s from the cache: %v", roleID, err)
+		return PrivilegeCacheEntry{}, false
+	}
+	if !exist || entry.expired(time.Now()) {
+		return PrivilegeCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// storeEntry writes the entry of a role to the cache of a tenant, bounded by the
+// configured TTL. A failed write only costs a later reload, so it is logged.
+func (s *rbacService) storeEntry(ctx context.Context, tenantID, roleID string, entry PrivilegeCacheEntry) {
+	if ttl := s.entryTTL(); ttl > 0 {
+		entry.ExpiresAt = earliest(entry.ExpiresAt, entry.LoadedAt.Add(ttl))
+	}
+	if err := s.cacheFor(tenantID).SetEntry(ctx, roleID, entry); err != nil {
+		s.logger.Errorf("Error writing role %s to the cache: %v", roleID, err)
+	}
+}
+
+// dropEntry removes the entry of a role from the cache of a tenant
+func (s *rbacService) dropEntry(ctx context.Context, tenantID, roleID string) {
+	cache, ok := s.existingCacheFor(tenantID)
+	if !ok {
+		return
+	}
+	if err := cache.DeleteEntry(ctx, roleID); err != nil {
+		s.logger.Errorf("Error deleting role %s from the cache: %v", roleID, err)
+	}
+	s.forgetIfEmpty(tenantID)
+}
+
+// dropExpiredEntry removes the entry of a role only if it has expired, so a newer
+// entry stored in the meantime survives
+func (s *rbacService) dropExpiredEntry(ctx context.Context, tenantID, roleID string) bool {
+	cache, ok := s.existingCacheFor(tenantID)
+	if !ok {
+		return false
+	}
+	if local, ok := cache.(*RolePrivilegesCache); ok {
+		dropped := local.deleteIfExpired(roleID)
+		s.forgetIfEmpty(tenantID)
+		return dropped
+	}
+
+	entry, exist, err := cache.GetEntry(ctx, roleID)
+	if err != nil || !exist || !entry.expired(time.Now()) {
+		return false
+	}
+	s.dropEntry(ctx, tenantID, roleID)
+	return true
+}
+
+// cachedKeys returns the roles cached for a tenant, dropping expired entries first
+// when the cache is in-process. A tenant left without roles is forgotten.
+func (s *rbacService) cachedKeys(ctx context.Context, tenantID string) []string {
+	cache, ok := s.existingCacheFor(tenantID)
+	if !ok {
+		return nil
+	}
+	if local, ok := cache.(*RolePrivilegesCache); ok {
+		// Purged roles are not refreshed; their grants did not change, so they
+		// are forgotten without a RoleChange
+		for _, roleID := range local.purgeExpired() {
+			s.loaded.remove(tenantRoleKey(tenantID, roleID))
+		}
+		s.forgetIfEmpty(tenantID)
+	}
+
+	keys, err := cache.Keys(ctx)
+	if err != nil {
+		s.logger.Errorf("Error listing cached roles: %v", err)
+		return nil
+	}
+	return keys
+}
@@ -1,8 +1,128 @@
 package rbac
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 // PrivilegeRepository abstracts data fetching so you can use GORM, pgx, raw SQL, etc.
+// FetchPrivilegesByRoleID should return ErrRoleNotFound for an unknown role.
 type PrivilegeRepository interface {
 	FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error)
 }
+
+// RoleParentRepository resolves the parent roles a role inherits privileges from.
+// It is optional; without it every role is flat.
+type RoleParentRepository interface {
+	FetchParentRoleIDs(ctx context.Context, roleID string) ([]string, error)
+}
+
+// TenantRoleParentRepository is optionally implemented by a RoleParentRepository that
+// stores role inheritance per tenant. Lookups made with a tenant in the context resolve
+// parents through it; without it they fail with ErrTenantsNotSupported.
+type TenantRoleParentRepository interface {
+	FetchTenantParentRoleIDs(ctx context.Context, tenantID string, roleID string) ([]string, error)
+}
+
+// ScopedGrant grants a privilege on a single resource, e.g. "edit:projects" on project "42".
+// A Privilege prefixed with DenyPrefix denies it on that resource instead.
+type ScopedGrant struct {
+	Privilege    string
+	ResourceType string
+	ResourceID   string
+}
+
+// ScopedPrivilegeRepository is optionally implemented by a PrivilegeRepository that also
+// stores privileges granted on specific resources. The service detects it automatically.
+type ScopedPrivilegeRepository interface {
+	FetchScopedPrivilegesByRoleID(ctx context.Context, roleID string) ([]ScopedGrant, error)
+}
+
+// TenantPrivilegeRepository is optionally implemented by a PrivilegeRepository that
+// stores privileges per tenant. The service detects it automatically and uses it for
+// every lookup made with a tenant in the context (see InjectTenant).
+type TenantPrivilegeRepository interface {
+	FetchTenantPrivilegesByRoleID(ctx context.Context, tenantID string, roleID string) (map[string]bool, error)
+}
+
+// TenantScopedPrivilegeRepository is optionally implemented by a TenantPrivilegeRepository
+// that also stores scoped grants per tenant. Lookups within a tenant merge them like
+// ScopedPrivilegeRepository's; a repository implementing only the latter fails them
+// with ErrTenantsNotSupported instead of ignoring its grants.
+type TenantScopedPrivilegeRepository interface {
+	FetchTenantScopedPrivilegesByRoleID(ctx context.Context, tenantID string, roleID string) ([]ScopedGrant, error)
+}
+
+// PrivilegeGrant is a privilege granted for a limited window, e.g. an auditor during
+// quarter close. A zero ValidFrom or ValidUntil leaves that side of the window open.
+type PrivilegeGrant struct {
+	Privilege  string
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// ActiveAt reports whether the grant is valid at t. ValidUntil is exclusive.
+func (g PrivilegeGrant) ActiveAt(t time.Time) bool {
+	if !g.ValidFrom.IsZero() && t.Before(g.ValidFrom) {
+		return false
+	}
+	if !g.ValidUntil.IsZero() && !t.Before(g.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// TimedPrivilegeRepository is optionally implemented by a PrivilegeRepository that also
+// stores time-bounded grants. The service detects it automatically.
+type TimedPrivilegeRepository interface {
+	FetchTimedPrivilegesByRoleID(ctx context.Context, roleID string) ([]PrivilegeGrant, error)
+}
+
+// TenantTimedPrivilegeRepository is optionally implemented by a TenantPrivilegeRepository
+// that also stores time-bounded grants per tenant. Lookups within a tenant merge them
+// like TimedPrivilegeRepository's; a repository implementing only the latter fails them
+// with ErrTenantsNotSupported instead of ignoring its grants.
+type TenantTimedPrivilegeRepository interface {
+	FetchTenantTimedPrivilegesByRoleID(ctx context.Context, tenantID string, roleID string) ([]PrivilegeGrant, error)
+}
+
+// BatchPrivilegeRepository is optionally implemented by a PrivilegeRepository that can
+// fetch the privileges of many roles in one call. The periodic refresh uses it to
+// reload cached roles in batches instead of one query per role.
+//
+// The result holds an entry for every requested role that exists, empty if the role
+// has no privileges. A requested role missing from it is treated as not found.
+type BatchPrivilegeRepository interface {
+	FetchPrivilegesByRoleIDs(ctx context.Context, roleIDs []string) (map[string]map[string]bool, error)
+}
+
+// StreamingPrivilegeRepository is optionally implemented by a PrivilegeRepository that
+// can read the privileges of every role in one pass. The periodic refresh reloads the
+// cached roles from a single FetchAll instead of one query per role, or instead of
+// batches past WithRefreshStreamingThreshold; roles it does not return are fetched
+// one by one.
+type StreamingPrivilegeRepository interface {
+	// FetchAll calls fn once for every role holding privileges, in role ID order,
+	// while reading them. An error from fn stops the pass and is returned.
+	FetchAll(ctx context.Context, fn func(roleID string, privileges map[string]bool) error) error
+}
+
+// ErrRoleNotFound is matched by errors.Is for every RoleNotFoundError. A repository
+// returns it (or a RoleNotFoundError) for a role that does not exist, and an empty
+// map for a role that exists but holds no privileges.
+var ErrRoleNotFound = errors.New("rbac: role not found")
+
+// RoleNotFoundError reports a role unknown to the repository
+type RoleNotFoundError struct {
+	RoleID string
+}
+
+func (e *RoleNotFoundError) Error() string {
+	return ErrRoleNotFound.Error() + ": " + e.RoleID
+}
+
+// Is makes errors.Is(err, ErrRoleNotFound) work
+func (e *RoleNotFoundError) Is(target error) bool {
+	return target == ErrRoleNotFound
+}
@@ -0,0 +1,143 @@
+package rbac
+
+import "sort"
+
+// PrivilegeSet is a read-only set of privilege codes. Sets are never modified once
+// built, so they can be shared freely between goroutines and with the cache; the
+// set operations return new sets. The zero value is an empty set.
+//
+// Membership is exact: Has("users:read") is false for a set holding "users:*".
+// Use the service or the context helpers for wildcard- and deny-aware checks.
+type PrivilegeSet struct {
+	codes map[string]bool // every value is true; never mutated after construction
+}
+
+// NewPrivilegeSet creates a set holding codes
+func NewPrivilegeSet(codes ...string) PrivilegeSet {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return PrivilegeSet{codes: set}
+}
+
+// PrivilegeSetFromMap creates a set holding the codes granted (true) in privileges.
+// The map is copied, so later changes to it do not affect the set.
+func PrivilegeSetFromMap(privileges map[string]bool) PrivilegeSet {
+	set := make(map[string]bool, len(privileges))
+	for code, granted := range privileges {
+		if granted {
+			set[code] = true
+		}
+	}
+	return PrivilegeSet{codes: set}
+}
+
+// Has reports whether the set holds code
+func (s PrivilegeSet) Has(code string) bool {
+	return s.codes[code]
+}
+
+// Any reports whether the set holds at least one of codes
+func (s PrivilegeSet) Any(codes ...string) bool {
+	for _, code := range codes {
+		if s.codes[code] {
+			return true
+		}
+	}
+	return false
+}
+
+// All reports whether the set holds every one of codes
+func (s PrivilegeSet) All(codes ...string) bool {
+	for _, code := range codes {
+		if !s.codes[code] {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of codes in the set
+func (s PrivilegeSet) Len() int {
+	return len(s.codes)
+}
+
+// List returns the codes of the set in sorted order
+func (s PrivilegeSet) List() []string {
+	codes := make([]string, 0, len(s.codes))
+	for code := range s.codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Union returns the codes held by s or other
+func (s PrivilegeSet) Union(other PrivilegeSet) PrivilegeSet {
+	if other.Len() == 0 {
+		return s
+	}
+	if s.Len() == 0 {
+		return other
+	}
+
+	union := make(map[string]bool, len(s.codes)+len(other.codes))
+	for code := range s.codes {
+		union[code] = true
+	}
+	for code := range other.codes {
+		union[code] = true
+	}
+	return PrivilegeSet{codes: union}
+}
+
+// Intersection returns the codes held by both s and other
+func (s PrivilegeSet) Intersection(other PrivilegeSet) PrivilegeSet {
+	small, large := s, other
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+
+	intersection := make(map[string]bool)
+	for code := range small.codes {
+		if large.codes[code] {
+			intersection[code] = true
+		}
+	}
+	return PrivilegeSet{codes: intersection}
+}
+
+// Difference returns the codes held by s but not by other
+func (s PrivilegeSet) Difference(other PrivilegeSet) PrivilegeSet {
+	difference := make(map[string]bool)
+	for code := range s.codes {
+		if !other.codes[code] {
+			difference[code] = true
+		}
+	}
+	return PrivilegeSet{codes: difference}
+}
+
+// Equal reports whether s and other hold the same codes
+func (s PrivilegeSet) Equal(other PrivilegeSet) bool {
+	if s.Len() != other.Len() {
+		return false
+	}
+	for code := range s.codes {
+		if !other.codes[code] {
+			return false
+		}
+	}
+	return true
+}
+
+// ToMap returns the set as a new map, for APIs taking map[string]bool. Changing the
+// map does not affect the set.
+func (s PrivilegeSet) ToMap() map[string]bool {
+	privileges := make(map[string]bool, len(s.codes))
+	for code := range s.codes {
+		privileges[code] = true
+	}
+	return privileges
+}
@@ -0,0 +1,175 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPrivilegeSet(t *testing.T) {
+	set := NewPrivilegeSet("read:users", "write:users", "!delete:users")
+
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{name: "has held code", got: set.Has("read:users"), want: true},
+		{name: "has missing code", got: set.Has("read:roles"), want: false},
+		{name: "has is exact", got: NewPrivilegeSet("users:*").Has("users:read"), want: false},
+		{name: "any with one held", got: set.Any("read:roles", "write:users"), want: true},
+		{name: "any with none held", got: set.Any("read:roles"), want: false},
+		{name: "any of nothing", got: set.Any(), want: false},
+		{name: "all held", got: set.All("read:users", "write:users"), want: true},
+		{name: "all with one missing", got: set.All("read:users", "read:roles"), want: false},
+		{name: "all of nothing", got: set.All(), want: true},
+		{name: "zero value is empty", got: PrivilegeSet{}.Len() == 0 && !PrivilegeSet{}.Has("read:users"), want: true},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrivilegeSet_Operations(t *testing.T) {
+	a := NewPrivilegeSet("read:users", "write:users")
+	b := NewPrivilegeSet("write:users", "read:roles")
+
+	tests := []struct {
+		name string
+		got  PrivilegeSet
+		want []string
+	}{
+		{name: "union", got: a.Union(b), want: []string{"read:roles", "read:users", "write:users"}},
+		{name: "union with empty", got: a.Union(PrivilegeSet{}), want: []string{"read:users", "write:users"}},
+		{name: "intersection", got: a.Intersection(b), want: []string{"write:users"}},
+		{name: "intersection with empty", got: a.Intersection(PrivilegeSet{}), want: []string{}},
+		{name: "difference", got: a.Difference(b), want: []string{"read:users"}},
+		{name: "difference of empty", got: PrivilegeSet{}.Difference(a), want: []string{}},
+		{name: "from map skips revoked codes", got: PrivilegeSetFromMap(map[string]bool{"read:users": true, "write:users": false}), want: []string{"read:users"}},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.List(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if a.Len() != 2 || b.Len() != 2 {
+		t.Errorf("operations modified their operands: %v, %v", a.List(), b.List())
+	}
+}
+
+func TestPrivilegeSet_Copies(t *testing.T) {
+	privileges := map[string]bool{"read:users": true}
+	set := PrivilegeSetFromMap(privileges)
+	privileges["write:users"] = true
+	if set.Has("write:users") {
+		t.Error("changing the source map changed the set")
+	}
+
+	m := set.ToMap()
+	m["write:users"] = true
+	if set.Has("write:users") {
+		t.Error("changing the result of ToMap changed the set")
+	}
+}
+
+func TestRBACService_GetRolePrivileges_ReturnsCopy(t *testing.T) {
+	svc, _ := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+	})
+	ctx := context.Background()
+
+	got, err := svc.GetRolePrivileges(ctx, "viewer")
+	if err != nil {
+		t.Fatalf("GetRolePrivileges() error = %v", err)
+	}
+	got["write:users"] = true
+
+	if ok, _ := svc.HasPrivilege(ctx, "viewer", "write:users"); ok {
+		t.Error("changing the returned map granted a privilege")
+	}
+	if cached, _ := svc.localCache().Get("viewer"); cached["write:users"] {
+		t.Error("changing the returned map changed the cache")
+	}
+
+	set, err := svc.GetRolePrivilegeSet(ctx, "viewer")
+	if err != nil {
+		t.Fatalf("GetRolePrivilegeSet() error = %v", err)
+	}
+	if !reflect.DeepEqual(set.List(), []string{"read:users"}) {
+		t.Errorf("GetRolePrivilegeSet() got = %v", set.List())
+	}
+}
+
+func TestGetPrivilegeSetFromContext(t *testing.T) {
+	privileges := map[string]bool{"read:users": true}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   []string
+		wantOk bool
+	}{
+		{
+			name:   "injected set",
+			ctx:    InjectPrivilegeSet(context.Background(), "viewer", "u1", NewPrivilegeSet("read:users")),
+			want:   []string{"read:users"},
+			wantOk: true,
+		},
+		{
+			name:   "injected map",
+			ctx:    InjectContext(context.Background(), "viewer", "u1", privileges),
+			want:   []string{"read:users"},
+			wantOk: true,
+		},
+		{
+			name:   "raw map under PrivilegesKey",
+			ctx:    context.WithValue(context.Background(), PrivilegesKey, privileges),
+			want:   []string{"read:users"},
+			wantOk: true,
+		},
+		{
+			name:   "nothing injected",
+			ctx:    context.Background(),
+			want:   []string{},
+			wantOk: false,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GetPrivilegeSetFromContext(tt.ctx)
+			if ok != tt.wantOk || !reflect.DeepEqual(got.List(), tt.want) {
+				t.Errorf("GetPrivilegeSetFromContext() got = %v, %v, want %v, %v", got.List(), ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGetPrivilegesFromContext_ReturnsCopy(t *testing.T) {
+	privileges := map[string]bool{"read:users": true}
+	ctx := InjectContext(context.Background(), "viewer", "u1", privileges)
+
+	// Neither the injected map nor the returned one is shared with the context
+	privileges["write:users"] = true
+	got, _ := GetPrivilegesFromContext(ctx)
+	got["delete:users"] = true
+
+	if HasPrivilegeInContext(ctx, "write:users") || HasPrivilegeInContext(ctx, "delete:users") {
+		t.Error("changing a map changed the privileges in the context")
+	}
+	if again, _ := GetPrivilegesFromContext(ctx); !reflect.DeepEqual(again, map[string]bool{"read:users": true}) {
+		t.Errorf("GetPrivilegesFromContext() got = %v", again)
+	}
+}
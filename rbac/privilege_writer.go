@@ -0,0 +1,30 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+)
+
+// PrivilegeWriter persists the privileges granted to roles. With WithPrivilegeWriter
+// the service writes SetNewRolePrivileges and DeleteRolePrivileges through to it
+// before touching the cache, so the periodic refresh no longer reverts them.
+// Each method should apply its change atomically.
+type PrivilegeWriter interface {
+	// CreateRolePrivileges grants privileges to a role that holds none yet, and
+	// returns ErrRoleExists otherwise
+	CreateRolePrivileges(ctx context.Context, roleID string, privileges []string) error
+
+	// ReplaceRolePrivileges makes privileges the only privileges granted to a role
+	ReplaceRolePrivileges(ctx context.Context, roleID string, privileges []string) error
+
+	// DeleteRolePrivileges revokes every privilege granted to a role
+	DeleteRolePrivileges(ctx context.Context, roleID string) error
+}
+
+// ErrRoleExists is returned when creating a role that already exists, e.g. by
+// PrivilegeWriter.CreateRolePrivileges for a role that already holds privileges
+var ErrRoleExists = errors.New("rbac: role already exists")
+
+// ErrUnknownPrivilege is returned by a PrivilegeWriter asked to grant a privilege
+// code its store does not know
+var ErrUnknownPrivilege = errors.New("rbac: unknown privilege")
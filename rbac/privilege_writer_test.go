@@ -0,0 +1,136 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// mockPrivilegeWriter writes into a mockPrivilegeRepository
+type mockPrivilegeWriter struct {
+	repo *mockPrivilegeRepository
+	err  error
+}
+
+func (w *mockPrivilegeWriter) CreateRolePrivileges(ctx context.Context, roleID string, privileges []string) error {
+	if _, ok := w.repo.privileges[roleID]; ok {
+		return ErrRoleExists
+	}
+	return w.ReplaceRolePrivileges(ctx, roleID, privileges)
+}
+
+func (w *mockPrivilegeWriter) ReplaceRolePrivileges(ctx context.Context, roleID string, privileges []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.repo.privileges[roleID] = NewPrivilegeSet(privileges...).ToMap()
+	return nil
+}
+
+func (w *mockPrivilegeWriter) DeleteRolePrivileges(ctx context.Context, roleID string) error {
+	if w.err != nil {
+		return w.err
+	}
+	delete(w.repo.privileges, roleID)
+	return nil
+}
+
+func TestRBACService_PrivilegeWriter(t *testing.T) {
+	errWrite := errors.New("db down")
+
+	tests := []struct {
+		name      string
+		writeErr  error
+		mutate    func(ctx context.Context, svc *rbacService) error
+		wantErr   error
+		wantStore map[string]bool
+		wantCache map[string]bool
+	}{
+		{
+			name: "set writes through",
+			mutate: func(ctx context.Context, svc *rbacService) error {
+				return svc.SetNewRolePrivileges(ctx, "viewer", []string{"write:users"})
+			},
+			wantStore: map[string]bool{"write:users": true},
+			wantCache: map[string]bool{"write:users": true},
+		},
+		{
+			name:     "failed set leaves the cache alone",
+			writeErr: errWrite,
+			mutate: func(ctx context.Context, svc *rbacService) error {
+				return svc.SetNewRolePrivileges(ctx, "viewer", []string{"write:users"})
+			},
+			wantErr:   errWrite,
+			wantStore: map[string]bool{"read:users": true},
+			wantCache: map[string]bool{"read:users": true},
+		},
+		{
+			name: "delete writes through",
+			mutate: func(ctx context.Context, svc *rbacService) error {
+				return svc.DeleteRolePrivileges(ctx, "viewer")
+			},
+			wantStore: nil,
+			wantCache: nil,
+		},
+		{
+			name:     "failed delete leaves the cache alone",
+			writeErr: errWrite,
+			mutate: func(ctx context.Context, svc *rbacService) error {
+				return svc.DeleteRolePrivileges(ctx, "viewer")
+			},
+			wantErr:   errWrite,
+			wantStore: map[string]bool{"read:users": true},
+			wantCache: map[string]bool{"read:users": true},
+		},
+		{
+			name: "invalidation does not write",
+			mutate: func(ctx context.Context, svc *rbacService) error {
+				return svc.InvalidateTenantRole(ctx, "", "viewer")
+			},
+			wantStore: map[string]bool{"read:users": true},
+			wantCache: nil,
+		},
+		{
+			name: "tenant roles are not written",
+			mutate: func(ctx context.Context, svc *rbacService) error {
+				return svc.SetNewRolePrivileges(InjectTenant(ctx, "acme"), "viewer", []string{"write:users"})
+			},
+			wantErr:   ErrTenantsNotSupported,
+			wantStore: map[string]bool{"read:users": true},
+			wantCache: map[string]bool{"read:users": true},
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockPrivilegeRepository{privileges: map[string]map[string]bool{"viewer": {"read:users": true}}}
+			writer := &mockPrivilegeWriter{repo: repo}
+			svc := NewRBACService(repo, 0, nil, WithPrivilegeWriter(writer)).(*rbacService)
+			ctx := context.Background()
+
+			if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+				t.Fatalf("GetRolePrivileges() error = %v", err)
+			}
+
+			writer.err = tt.writeErr
+			if err := tt.mutate(ctx, svc); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got := repo.privileges["viewer"]; !reflect.DeepEqual(got, tt.wantStore) {
+				t.Errorf("store got = %v, want %v", got, tt.wantStore)
+			}
+			if got, _ := svc.localCache().Get("viewer"); !reflect.DeepEqual(got, tt.wantCache) {
+				t.Errorf("cache got = %v, want %v", got, tt.wantCache)
+			}
+
+			// A refresh reloads what the store holds, so nothing is reverted
+			svc.refreshCache(ctx, svc.cachedKeys(ctx, ""))
+			if got, _ := svc.localCache().Get("viewer"); !reflect.DeepEqual(got, tt.wantCache) {
+				t.Errorf("cache after refresh got = %v, want %v", got, tt.wantCache)
+			}
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package rbac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Requirement is a compiled boolean expression over privilege codes, e.g.
+//
+//	read:report && (export:pdf || export:csv)
+//	2 of (read:users, write:users, delete:users)
+//
+// Build one with ParseRequirement or the AllOf, AnyOf and AtLeast helpers.
+// A Requirement is immutable and safe for concurrent use.
+type Requirement struct {
+	root requirementNode
+}
+
+type requirementNode interface {
+	eval(has func(string) bool) bool
+	String() string
+}
+
+type privilegeNode struct {
+	code string
+}
+
+type allOfNode struct {
+	children []requirementNode
+}
+
+type anyOfNode struct {
+	children []requirementNode
+}
+
+type atLeastNode struct {
+	n        int
+	children []requirementNode
+}
+
+func (n privilegeNode) eval(has func(string) bool) bool {
+	return has(n.code)
+}
+
+// An empty all-of never holds, so a missing list of privileges fails closed
+func (n allOfNode) eval(has func(string) bool) bool {
+	if len(n.children) == 0 {
+		return false
+	}
+	for _, child := range n.children {
+		if !child.eval(has) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n anyOfNode) eval(has func(string) bool) bool {
+	for _, child := range n.children {
+		if child.eval(has) {
+			return true
+		}
+	}
+	return false
+}
+
+// A count below 1 never holds, like a count the parser rejects
+func (n atLeastNode) eval(has func(string) bool) bool {
+	if n.n < 1 {
+		return false
+	}
+	matched := 0
+	for i, child := range n.children {
+		if child.eval(has) {
+			matched++
+		}
+		if matched >= n.n {
+			return true
+		}
+		if matched+len(n.children)-i-1 < n.n {
+			return false
+		}
+	}
+	return false
+}
+
+func (n privilegeNode) String() string {
+	return n.code
+}
+
+func (n allOfNode) String() string {
+	if len(n.children) == 0 {
+		return "AllOf()"
+	}
+	return joinNodes(n.children, " && ", true)
+}
+
+func (n anyOfNode) String() string {
+	if len(n.children) == 0 {
+		return "AnyOf()"
+	}
+	return joinNodes(n.children, " || ", true)
+}
+
+func (n atLeastNode) String() string {
+	return strconv.Itoa(n.n) + " of (" + joinNodes(n.children, ", ", false) + ")"
+}
+
+func joinNodes(nodes []requirementNode, sep string, group bool) string {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = node.String()
+		if _, ok := node.(privilegeNode); !ok && group {
+			if _, ok := node.(atLeastNode); !ok {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// emptyNode is the root of a nil or zero Requirement, which never holds
+type emptyNode struct{}
+
+func (emptyNode) eval(has func(string) bool) bool {
+	return false
+}
+
+func (emptyNode) String() string {
+	return "empty requirement"
+}
+
+// node returns the root of the requirement, failing closed for a nil or zero one
+func (r *Requirement) node() requirementNode {
+	if r == nil || r.root == nil {
+		return emptyNode{}
+	}
+	return r.root
+}
+
+// Evaluate reports whether the requirement holds, using has to check single
+// privileges. A nil or zero Requirement never holds.
+func (r *Requirement) Evaluate(has func(privilege string) bool) bool {
+	return r.node().eval(has)
+}
+
+// unmet returns the most specific part of the requirement that does not hold,
+// e.g. "export:pdf || export:csv" for "read:report && (export:pdf || export:csv)"
+// when neither export privilege is held. It returns "" when the requirement holds.
+func (r *Requirement) unmet(has func(privilege string) bool) string {
+	node := r.node()
+	for {
+		if node.eval(has) {
+			return ""
+		}
+		all, ok := node.(allOfNode)
+		if !ok || len(all.children) == 0 {
+			return node.String()
+		}
+		for _, child := range all.children {
+			if !child.eval(has) {
+				node = child
+				break
+			}
+		}
+	}
+}
+
+// String returns the requirement in expression syntax
+func (r *Requirement) String() string {
+	return r.node().String()
+}
+
+// Privileges returns every privilege code referenced by the requirement
+func (r *Requirement) Privileges() []string {
+	var codes []string
+	var walk func(node requirementNode)
+	walk = func(node requirementNode) {
+		switch n := node.(type) {
+		case privilegeNode:
+			codes = append(codes, n.code)
+		case allOfNode:
+			for _, child := range n.children {
+				walk(child)
+			}
+		case anyOfNode:
+			for _, child := range n.children {
+				walk(child)
+			}
+		case atLeastNode:
+			for _, child := range n.children {
+				walk(child)
+			}
+		}
+	}
+	walk(r.node())
+	return codes
+}
+
+// AllOf requires every given privilege. Without any it never holds.
+func AllOf(privilegeCodes ...string) *Requirement {
+	return &Requirement{root: allOfNode{children: privilegeNodes(privilegeCodes)}}
+}
+
+// AnyOf requires at least one of the given privileges. Without any it never holds.
+func AnyOf(privilegeCodes ...string) *Requirement {
+	return &Requirement{root: anyOfNode{children: privilegeNodes(privilegeCodes)}}
+}
+
+// AtLeast requires at least n of the given privileges. It never holds for n < 1 or
+// fewer than n privileges.
+func AtLeast(n int, privilegeCodes ...string) *Requirement {
+	return &Requirement{root: atLeastNode{n: n, children: privilegeNodes(privilegeCodes)}}
+}
+
+func privilegeNodes(codes []string) []requirementNode {
+	nodes := make([]requirementNode, len(codes))
+	for i, code := range codes {
+		nodes[i] = privilegeNode{code: code}
+	}
+	return nodes
+}
+
+// ParseError describes why a requirement expression could not be parsed
+type ParseError struct {
+	Expr string // the full expression
+	Pos  int    // byte offset of the problem in Expr
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("rbac: invalid requirement %q at position %d: %s", e.Expr, e.Pos, e.Msg)
+}
+
+// MustParseRequirement is like ParseRequirement but panics on error.
+// It is meant for requirements declared as package level variables.
+func MustParseRequirement(expr string) *Requirement {
+	req, err := ParseRequirement(expr)
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
+
+// ParseRequirement compiles a requirement expression. The grammar is
+//
+//	expr    = and { "||" and }
+//	and     = term { "&&" term }
+//	term    = privilege | "(" expr ")" | N "of" "(" expr { "," expr } ")"
+//
+// where privilege is any privilege code, wildcards included. "&&" binds
+// tighter than "||".
+func ParseRequirement(expr string) (*Requirement, error) {
+	p := &requirementParser{expr: expr}
+
+	p.next()
+	if p.tok.kind == tokenEOF {
+		return nil, p.errorf(p.tok.pos, "empty expression")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected("\"&&\", \"||\" or end of expression")
+	}
+
+	return &Requirement{root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenAnd
+	tokenOr
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+type requirementParser struct {
+	expr string
+	pos  int
+	tok  token
+}
+
+func (p *requirementParser) errorf(pos int, format string, args ...interface{}) *ParseError {
+	return &ParseError{Expr: p.expr, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// unexpected reports the current token as unexpected
+func (p *requirementParser) unexpected(expected string) *ParseError {
+	if p.tok.kind == tokenInvalid {
+		op := "&&"
+		if p.tok.text == "|" {
+			op = "||"
+		}
+		return p.errorf(p.tok.pos, "unexpected %s, did you mean %q?", p.tok, op)
+	}
+	return p.errorf(p.tok.pos, "unexpected %s, expected %s", p.tok, expected)
+}
+
+// next advances to the next token
+func (p *requirementParser) next() {
+	for p.pos < len(p.expr) && isSpace(p.expr[p.pos]) {
+		p.pos++
+	}
+
+	start := p.pos
+	if p.pos >= len(p.expr) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+
+	switch c := p.expr[p.pos]; c {
+	case '(':
+		p.pos++
+		p.tok = token{kind: tokenLParen, text: "(", pos: start}
+	case ')':
+		p.pos++
+		p.tok = token{kind: tokenRParen, text: ")", pos: start}
+	case ',':
+		p.pos++
+		p.tok = token{kind: tokenComma, text: ",", pos: start}
+	case '&', '|':
+		if p.pos+1 < len(p.expr) && p.expr[p.pos+1] == c {
+			p.pos += 2
+			kind := tokenAnd
+			if c == '|' {
+				kind = tokenOr
+			}
+			p.tok = token{kind: kind, text: p.expr[start:p.pos], pos: start}
+			return
+		}
+		p.pos++
+		p.tok = token{kind: tokenInvalid, text: string(c), pos: start}
+	default:
+		for p.pos < len(p.expr) && !isSpace(p.expr[p.pos]) && !strings.ContainsRune("(),&|", rune(p.expr[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenIdent, text: p.expr[start:p.pos], pos: start}
+	}
+}
+
+func (p *requirementParser) parseOr() (requirementNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []requirementNode{first}
+	for p.tok.kind == tokenOr {
+		p.next()
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return anyOfNode{children: children}, nil
+}
+
+func (p *requirementParser) parseAnd() (requirementNode, error) {
+	first, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []requirementNode{first}
+	for p.tok.kind == tokenAnd {
+		p.next()
+		child, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return allOfNode{children: children}, nil
+}
+
+func (p *requirementParser) parseTerm() (requirementNode, error) {
+	tok := p.tok
+
+	switch tok.kind {
+	case tokenLParen:
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenRParen {
+			return nil, p.unexpected(fmt.Sprintf("\")\" to close \"(\" at position %d", tok.pos))
+		}
+		p.next()
+		return node, nil
+
+	case tokenIdent:
+		if strings.HasPrefix(tok.text, DenyPrefix) {
+			return nil, p.errorf(tok.pos, "negation is not supported in requirements; configure denies on the role instead")
+		}
+		p.next()
+		if p.tok.kind == tokenIdent && p.tok.text == "of" {
+			return p.parseAtLeast(tok)
+		}
+		return privilegeNode{code: tok.text}, nil
+
+	default:
+		return nil, p.unexpected("a privilege or \"(\"")
+	}
+}
+
+// parseAtLeast parses the rest of "N of (a, b, ...)"; count is the N token
+// and the current token is "of"
+func (p *requirementParser) parseAtLeast(count token) (requirementNode, error) {
+	n, err := strconv.Atoi(count.text)
+	if err != nil || n < 1 {
+		return nil, p.errorf(count.pos, "%s is not a positive count for \"of\"", count)
+	}
+
+	p.next()
+	if p.tok.kind != tokenLParen {
+		return nil, p.unexpected(fmt.Sprintf("\"(\" after \"%d of\"", n))
+	}
+	open := p.tok.pos
+
+	var children []requirementNode
+	for {
+		p.next()
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+
+		if p.tok.kind == tokenComma {
+			continue
+		}
+		if p.tok.kind != tokenRParen {
+			return nil, p.unexpected(fmt.Sprintf("\",\" or \")\" to close \"(\" at position %d", open))
+		}
+		break
+	}
+
+	if n > len(children) {
+		return nil, p.errorf(count.pos, "\"%d of\" needs at least %d alternatives, got %d", n, n, len(children))
+	}
+
+	p.next()
+	return atLeastNode{n: n, children: children}, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// parsedRequirements caches expressions parsed by the string based helpers.
+// Those helpers are meant for expressions written in code, not user input.
+var parsedRequirements sync.Map // map[string]*Requirement
+
+// parseRequirementCached parses expr once and reuses the result
+func parseRequirementCached(expr string) (*Requirement, error) {
+	if req, ok := parsedRequirements.Load(expr); ok {
+		return req.(*Requirement), nil
+	}
+
+	req, err := ParseRequirement(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedRequirements.Store(expr, req)
+	return req, nil
+}
@@ -0,0 +1,179 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseRequirement(t *testing.T) {
+	granted := map[string]bool{
+		"read:report": true,
+		"export:csv":  true,
+		"users:*":     true,
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want bool
+		str  string
+	}{
+		{name: "single privilege", expr: "read:report", want: true, str: "read:report"},
+		{name: "and", expr: "read:report && export:pdf", want: false, str: "read:report && export:pdf"},
+		{name: "or", expr: "export:pdf || export:csv", want: true, str: "export:pdf || export:csv"},
+		{name: "and binds tighter than or", expr: "export:pdf && read:report || export:csv", want: true, str: "(export:pdf && read:report) || export:csv"},
+		{name: "nested", expr: "read:report && (export:pdf || export:csv)", want: true, str: "read:report && (export:pdf || export:csv)"},
+		{name: "n of m satisfied", expr: "2 of (read:report, export:pdf, export:csv)", want: true, str: "2 of (read:report, export:pdf, export:csv)"},
+		{name: "n of m unsatisfied", expr: "3 of (read:report, export:pdf, export:csv)", want: false, str: "3 of (read:report, export:pdf, export:csv)"},
+		{name: "n of m with nested terms", expr: "1 of (export:pdf && read:report, users:delete)", want: true, str: "1 of (export:pdf && read:report, users:delete)"},
+		{name: "wildcard grant applies", expr: "users:delete", want: true, str: "users:delete"},
+		{name: "extra whitespace", expr: "  ( read:report\t&&export:csv )  ", want: true, str: "read:report && export:csv"},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseRequirement() error = %v", err)
+			}
+			if got := req.Evaluate(newPrivilegeMatcher(granted).has); got != tt.want {
+				t.Errorf("Requirement.Evaluate() got = %v, want %v", got, tt.want)
+			}
+			if got := req.String(); got != tt.str {
+				t.Errorf("Requirement.String() got = %q, want %q", got, tt.str)
+			}
+		})
+	}
+}
+
+func TestParseRequirement_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantPos int
+		wantMsg string
+	}{
+		{name: "empty", expr: "   ", wantPos: 3, wantMsg: "empty expression"},
+		{name: "single ampersand", expr: "a & b", wantPos: 2, wantMsg: `did you mean "&&"`},
+		{name: "single pipe", expr: "a | b", wantPos: 2, wantMsg: `did you mean "||"`},
+		{name: "dangling operator", expr: "a &&", wantPos: 4, wantMsg: "unexpected end of expression"},
+		{name: "unclosed paren", expr: "(a || b", wantPos: 7, wantMsg: `to close "(" at position 0`},
+		{name: "stray paren", expr: "a)", wantPos: 1, wantMsg: `unexpected ")"`},
+		{name: "missing operator", expr: "a b", wantPos: 2, wantMsg: `expected "&&", "||" or end of expression`},
+		{name: "bad count", expr: "x of (a, b)", wantPos: 0, wantMsg: "not a positive count"},
+		{name: "zero count", expr: "0 of (a, b)", wantPos: 0, wantMsg: "not a positive count"},
+		{name: "count too large", expr: "3 of (a, b)", wantPos: 0, wantMsg: "needs at least 3 alternatives, got 2"},
+		{name: "of without list", expr: "2 of a", wantPos: 5, wantMsg: `expected "(" after "2 of"`},
+		{name: "negation", expr: "a && !b", wantPos: 5, wantMsg: "negation is not supported"},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRequirement(tt.expr)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseRequirement() error = %v, want *ParseError", err)
+			}
+			if parseErr.Pos != tt.wantPos {
+				t.Errorf("ParseError.Pos = %d, want %d (%v)", parseErr.Pos, tt.wantPos, err)
+			}
+			if !strings.Contains(parseErr.Msg, tt.wantMsg) {
+				t.Errorf("ParseError.Msg = %q, want it to contain %q", parseErr.Msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestMeetsExpressionInContext(t *testing.T) {
+	ctx := InjectContext(context.Background(), "analyst", "u1", map[string]bool{
+		"read:report": true,
+		"export:csv":  true,
+	})
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "satisfied", expr: "read:report && (export:pdf || export:csv)", want: true},
+		{name: "unsatisfied", expr: "read:report && export:pdf", want: false},
+		{name: "invalid", expr: "read:report &&", wantErr: true},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MeetsExpressionInContext(ctx, tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MeetsExpressionInContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MeetsExpressionInContext() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !HasAllPrivilegesInContext(ctx, "read:report", "export:csv") {
+		t.Errorf("HasAllPrivilegesInContext() should be true")
+	}
+	if HasAllPrivilegesInContext(ctx, "read:report", "export:pdf") {
+		t.Errorf("HasAllPrivilegesInContext() should be false")
+	}
+}
+
+func TestRequirementHelpers_FailClosed(t *testing.T) {
+	has := func(privilege string) bool { return privilege == "read:report" || privilege == "export:csv" }
+
+	tests := []struct {
+		name string
+		req  *Requirement
+		want bool
+	}{
+		{name: "all of", req: AllOf("read:report", "export:csv"), want: true},
+		{name: "empty all of", req: AllOf(), want: false},
+		{name: "empty any of", req: AnyOf(), want: false},
+		{name: "at least", req: AtLeast(2, "read:report", "export:csv", "export:pdf"), want: true},
+		{name: "at least zero", req: AtLeast(0, "read:report"), want: false},
+		{name: "at least zero of none", req: AtLeast(0), want: false},
+		{name: "at least negative", req: AtLeast(-1, "read:report"), want: false},
+		{name: "at least more than given", req: AtLeast(2, "read:report"), want: false},
+		{name: "nil", req: nil, want: false},
+		{name: "zero", req: &Requirement{}, want: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req.Evaluate(has); got != tt.want {
+				t.Errorf("Evaluate() got = %v, want %v", got, tt.want)
+			}
+			if !tt.want && tt.req.unmet(has) == "" {
+				t.Errorf("unmet() should name the failing part")
+			}
+			if tt.req.String() == "" {
+				t.Errorf("String() should not be empty")
+			}
+		})
+	}
+
+	svc, _ := newTestService(map[string]map[string]bool{"admin": {"*": true}})
+	if ok, err := svc.HasAllPrivileges(context.Background(), "admin"); err != nil || ok {
+		t.Errorf("HasAllPrivileges() without privileges got = %v, %v, want false", ok, err)
+	}
+	if ok, err := svc.MeetsRequirement(context.Background(), "admin", nil); err != nil || ok {
+		t.Errorf("MeetsRequirement() with a nil requirement got = %v, %v, want false", ok, err)
+	}
+	decision, err := svc.ExplainRequirement(context.Background(), []string{"admin"}, nil)
+	if err != nil || decision.Allowed || decision.FailedRequirement != "empty requirement" {
+		t.Errorf("ExplainRequirement() with a nil requirement got = %+v, %v", decision, err)
+	}
+	ctx := InjectContext(context.Background(), "admin", "u1", map[string]bool{"*": true})
+	if HasAllPrivilegesInContext(ctx) {
+		t.Errorf("HasAllPrivilegesInContext() without privileges should be false")
+	}
+}
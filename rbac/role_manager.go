@@ -0,0 +1,54 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultPageLimit is the number of items listed when Page.Limit is not set
+const DefaultPageLimit = 100
+
+// Role is a role as managed through a RoleManager. ID is what privileges are looked
+// up by and never changes; Name is for display.
+type Role struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Privilege is a privilege code registered with a RoleManager
+type Privilege struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+}
+
+// Page selects part of a listing: at most Limit items, skipping the first Offset, in
+// a stable order. A Limit of 0 or less means DefaultPageLimit.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// RoleManager administers roles and privileges in the store the PrivilegeRepository
+// reads from. Implementations keep the service's cache in sync with their changes.
+//
+// Methods taking a role ID return a RoleNotFoundError for an unknown role, and
+// granting a code that was never registered fails with ErrUnknownPrivilege. List
+// methods return the requested page and the total number of items.
+type RoleManager interface {
+	CreateRole(ctx context.Context, role Role) error
+	RenameRole(ctx context.Context, roleID string, name string) error
+	DeleteRole(ctx context.Context, roleID string) error
+
+	RegisterPrivilege(ctx context.Context, privilege Privilege) error
+	GrantPrivileges(ctx context.Context, roleID string, codes ...string) error
+	RevokePrivileges(ctx context.Context, roleID string, codes ...string) error
+
+	ListRoles(ctx context.Context, page Page) ([]Role, int, error)
+	ListPrivileges(ctx context.Context, page Page) ([]Privilege, int, error)
+	ListRolePrivileges(ctx context.Context, roleID string, page Page) ([]Privilege, int, error)
+}
+
+// ErrPrivilegeExists is returned by RoleManager.RegisterPrivilege for a code that is
+// already registered
+var ErrPrivilegeExists = errors.New("rbac: privilege already exists")
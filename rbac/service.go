@@ -2,117 +2,487 @@ package rbac
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 )
 
 type RBACService interface {
 	GetRolePrivileges(ctx context.Context, roleID string) (map[string]bool, error)
+	GetRolePrivilegeSet(ctx context.Context, roleID string) (PrivilegeSet, error)
 	HasPrivilege(ctx context.Context, roleID string, privilege string) (bool, error)
+	HasPrivilegeHandle(ctx context.Context, roleID string, privilege PrivilegeHandle) (bool, error)
 	HasAnyPrivilege(ctx context.Context, roleID string, privilegeCodes ...string) (bool, error)
+	HasAllPrivileges(ctx context.Context, roleID string, privilegeCodes ...string) (bool, error)
+	MeetsRequirement(ctx context.Context, roleID string, req *Requirement) (bool, error)
+	HasPrivilegeOn(ctx context.Context, roleID string, privilege string, resourceType string, resourceID string) (bool, error)
 	SetNewRolePrivileges(ctx context.Context, roleID string, privileges []string) error
 	DeleteRolePrivileges(ctx context.Context, roleID string) error
+
+	GetRolesPrivileges(ctx context.Context, roleIDs []string) (map[string]bool, error)
+	GetRolesPrivilegeSet(ctx context.Context, roleIDs []string) (PrivilegeSet, error)
+	HasPrivilegeInRoles(ctx context.Context, roleIDs []string, privilege string) (bool, error)
+	HasAnyPrivilegeInRoles(ctx context.Context, roleIDs []string, privilegeCodes ...string) (bool, error)
+	MeetsRequirementInRoles(ctx context.Context, roleIDs []string, req *Requirement) (bool, error)
+	HasPrivilegeOnInRoles(ctx context.Context, roleIDs []string, privilege string, resourceType string, resourceID string) (bool, error)
+	GetUserRoleIDs(ctx context.Context, userID string) ([]string, error)
+	HasUserPrivilege(ctx context.Context, userID string, privilege string) (bool, error)
+
+	GetTenantRolePrivileges(ctx context.Context, tenantID string, roleID string) (map[string]bool, error)
+	HasTenantPrivilege(ctx context.Context, tenantID string, roleID string, privilege string) (bool, error)
+	InvalidateTenantRole(ctx context.Context, tenantID string, roleID string) error
+	InvalidateTenant(ctx context.Context, tenantID string) error
+
+	Explain(ctx context.Context, roleID string, privilege string) (*Decision, error)
+	ExplainRoles(ctx context.Context, roleIDs []string, privilege string) (*Decision, error)
+	ExplainRequirement(ctx context.Context, roleIDs []string, req *Requirement) (*Decision, error)
+
+	Start() error
+	Stop(ctx context.Context) error
+	Close() error
+
+	Stats() Stats
+	CacheEntry(ctx context.Context, roleID string) (CacheEntryInfo, bool)
+	OnRoleChanged(handler func(RoleChange)) (unsubscribe func())
 }
 
 type rbacService struct {
-	repo   PrivilegeRepository // decoupled abstraction
-	cache  *RolePrivilegesCache
-	logger Logger
+	repo       PrivilegeRepository       // decoupled abstraction
+	tenantRepo TenantPrivilegeRepository // set when repo is tenant-aware
+	userRoles  UserRoleRepository        // optional, resolves user -> roles
+	parents    RoleParentRepository      // optional, enables role inheritance
+	writer     PrivilegeWriter           // optional, persists role changes
+	cache      PrivilegeCache
+	tenants    *TenantPrivilegesCache
+	hierarchy  *roleHierarchy
+	loads      loadGroup
+	negative   negativeCache
+	stats      serviceStats
+	changes    roleChangeSubscribers
+	loaded     loadedSets
+	logger     Logger
+
+	timersMu sync.Mutex
+	timers   map[string]*time.Timer // scheduled expiries, by tenantRoleKey
+
+	refreshInterval time.Duration
+	negativeTTL     time.Duration
+	manualStart     bool
+	lifecycle       refresherLifecycle
+
+	tenantCacheLimit int
+	cacheMaxEntries  int
+	cacheTTL         time.Duration
+	onEvict          func(Eviction)
+
+	compiledPrivileges bool
+
+	refreshConcurrency     int
+	refreshBatchSize       int
+	refreshStreamThreshold int
+	refreshJitter          time.Duration
+
+	bus         InvalidationBus
+	instanceID  string
+	unsubscribe func()
+
+	staleAfter   time.Duration
+	maxStaleAge  time.Duration
+	revalidating sync.Map // tenantRoleKey -> struct{}, roles reloading in the background
 }
 
 // NewRBACService creates a new RBAC service
-func NewRBACService(repo PrivilegeRepository, refreshInterval time.Duration, logger Logger) RBACService {
+func NewRBACService(repo PrivilegeRepository, refreshInterval time.Duration, logger Logger, opts ...Option) RBACService {
 	if logger == nil {
 		logger = NewNullLogger()
 	}
 
 	svc := &rbacService{
-		repo:   repo,
-		cache:  NewRolePrivilegesCache(),
-		logger: logger,
+		repo:            repo,
+		hierarchy:       newRoleHierarchy(),
+		logger:          logger,
+		refreshInterval: refreshInterval,
+		instanceID:      newInstanceID(),
+	}
+
+	for _, opt := range opts {
+		opt(svc)
 	}
 
-	// Start periodic refresh if interval is greater than 0
-	if refreshInterval > 0 {
-		go svc.startPeriodicRefresh(refreshInterval)
+	svc.tenantRepo, _ = repo.(TenantPrivilegeRepository)
+	if svc.cache == nil {
+		svc.cache = newConfiguredRolePrivilegesCache(cacheConfig{
+			maxEntries: svc.cacheMaxEntries,
+			ttl:        svc.entryTTL(),
+			compiled:   svc.compiledPrivileges,
+			onEvict: func(roleID string, reason EvictionReason) {
+				svc.evicted("", roleID, reason)
+			},
+		})
+	}
+	svc.tenants = NewTenantPrivilegesCache(svc.tenantCacheLimit)
+	svc.tenants.ttl = svc.entryTTL()
+	svc.tenants.onEvict = svc.evicted
+	svc.tenants.compiled = svc.compiledPrivileges
+	svc.subscribeInvalidations()
+
+	// Start periodic refresh if interval is greater than 0, unless the caller
+	// controls the refresher through Start and Stop
+	if !svc.manualStart {
+		svc.Start()
 	}
 
 	return svc
 }
 
 // loadRolePrivileges loads the privileges for a given role ID from the database
-// and caches them
-func (s *rbacService) loadRolePrivileges(ctx context.Context, roleID string) (map[string]bool, error) {
+// and caches them. With role inheritance enabled the cached privileges are the
+// transitive closure over all ancestors. The tenant, if any, is taken from ctx.
+func (s *rbacService) loadRolePrivileges(ctx context.Context, roleID string) (PrivilegeSet, error) {
+	return s.loadRolePrivilegesFrom(ctx, roleID, s.fetchOwnPrivileges)
+}
 
-	privileges, err := s.repo.FetchPrivilegesByRoleID(ctx, roleID)
+// ownPrivilegesFunc fetches the privileges granted directly to a role and when
+// they stop being valid, like fetchOwnPrivileges
+type ownPrivilegesFunc func(ctx context.Context, roleID string) (map[string]bool, time.Time, error)
+
+// loadRolePrivilegesFrom is loadRolePrivileges reading each role's own privileges
+// through fetch
+func (s *rbacService) loadRolePrivilegesFrom(ctx context.Context, roleID string, fetch ownPrivilegesFunc) (PrivilegeSet, error) {
+
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	s.stats.loads.Add(1)
+
+	if s.parents == nil {
+		own, expiresAt, err := fetch(ctx, roleID)
+		if err != nil {
+			return PrivilegeSet{}, s.handleLoadError(tenantID, roleID, err)
+		}
+
+		// The repository may keep using its map; the set takes a copy
+		privileges := PrivilegeSetFromMap(own)
+		previous, loaded := s.loaded.swap(tenantRoleKey(tenantID, roleID), privileges)
+		s.storeEntry(ctx, tenantID, roleID, PrivilegeCacheEntry{Privileges: privileges, LoadedAt: time.Now(), ExpiresAt: expiresAt})
+		s.scheduleExpiry(tenantID, roleID, expiresAt)
+
+		if loaded {
+			s.roleReloaded(tenantID, roleID, previous, privileges)
+		}
+		return privileges, nil
+	}
+
+	ancestors, err := s.resolveAncestors(ctx, roleID)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, ErrRoleCycle) {
+			s.logger.Errorf("Cannot load privileges for role %s: %v", roleID, err)
+		}
+		s.stats.loadErrors.Add(1)
+		return PrivilegeSet{}, err
+	}
+
+	union := make(map[string]bool)
+	origins := make(map[string][]string)
+	var expiresAt time.Time
+	for _, role := range append([]string{roleID}, ancestors...) {
+		own, ownExpiresAt, err := fetch(ctx, role)
+		if err != nil {
+			if role == roleID {
+				return PrivilegeSet{}, s.handleLoadError(tenantID, roleID, err)
+			}
+			// A missing parent must not make the role itself look missing
+			s.stats.loadErrors.Add(1)
+			return PrivilegeSet{}, fmt.Errorf("rbac: loading parent role %s of %s: %w", role, roleID, err)
+		}
+		for code, granted := range own {
+			if granted {
+				union[code] = true
+				origins[code] = append(origins[code], role)
+			}
+		}
+		expiresAt = earliest(expiresAt, ownExpiresAt)
 	}
 
-	s.cache.Set(roleID, privileges)
+	privileges := PrivilegeSet{codes: union}
+	previous, loaded := s.loaded.swap(tenantRoleKey(tenantID, roleID), privileges)
+	s.storeEntry(ctx, tenantID, roleID, PrivilegeCacheEntry{
+		Privileges: privileges,
+		LoadedAt:   time.Now(),
+		ExpiresAt:  expiresAt,
+		Origins:    origins,
+	})
+	s.scheduleExpiry(tenantID, roleID, expiresAt)
+	s.hierarchy.track(tenantID, roleID, ancestors)
+
+	if loaded && s.roleReloaded(tenantID, roleID, previous, privileges) {
+		s.invalidateDependents(tenantID, roleID)
+	}
 
 	return privileges, nil
 }
 
+// fetchOwnPrivileges fetches the privileges granted directly to a role, merging in
+// scoped and time-bounded grants when the repository provides them. It also returns
+// when the result stops being valid because a time-bounded grant starts or ends
+// (zero if never). Within a tenant only the tenant repository is consulted, so
+// tenants never see each other's grants.
+func (s *rbacService) fetchOwnPrivileges(ctx context.Context, roleID string) (map[string]bool, time.Time, error) {
+
+	if tenantID, ok := GetTenantIDFromContext(ctx); ok && tenantID != "" {
+		if s.tenantRepo == nil {
+			return nil, time.Time{}, ErrTenantsNotSupported
+		}
+		privileges, err := s.tenantRepo.FetchTenantPrivilegesByRoleID(ctx, tenantID, roleID)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		return s.withExtraGrants(ctx, roleID, privileges)
+	}
+
+	privileges, err := s.repo.FetchPrivilegesByRoleID(ctx, roleID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return s.withExtraGrants(ctx, roleID, privileges)
+}
+
+// withExtraGrants merges the scoped and time-bounded grants of a role into the
+// privileges read from the base repository, within the tenant in ctx if any
+func (s *rbacService) withExtraGrants(ctx context.Context, roleID string, privileges map[string]bool) (map[string]bool, time.Time, error) {
+	// Extra grants are merged into a copy; the repository may own privileges
+	var extra []string
+
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	scoped, err := s.fetchScopedGrants(ctx, tenantID, roleID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for _, grant := range scoped {
+		extra = append(extra, ScopedPrivilege(grant.Privilege, grant.ResourceType, grant.ResourceID))
+	}
+
+	timed, err := s.fetchTimedGrants(ctx, tenantID, roleID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	active, expiresAt := activeGrants(timed, time.Now())
+	extra = append(extra, active...)
+
+	if len(extra) == 0 {
+		return privileges, expiresAt, nil
+	}
+
+	merged := make(map[string]bool, len(privileges)+len(extra))
+	for code, granted := range privileges {
+		merged[code] = granted
+	}
+	for _, code := range extra {
+		merged[code] = true
+	}
+
+	return merged, expiresAt, nil
+}
+
+// fetchScopedGrants returns the scoped grants of a role within a tenant, nil when the
+// repository stores none. A repository keeping scoped grants outside tenants only
+// cannot serve a tenant: leaving out its denies would widen access.
+func (s *rbacService) fetchScopedGrants(ctx context.Context, tenantID, roleID string) ([]ScopedGrant, error) {
+	if tenantID == "" {
+		if scopedRepo, ok := s.repo.(ScopedPrivilegeRepository); ok {
+			return scopedRepo.FetchScopedPrivilegesByRoleID(ctx, roleID)
+		}
+		return nil, nil
+	}
+
+	if scopedRepo, ok := s.repo.(TenantScopedPrivilegeRepository); ok {
+		return scopedRepo.FetchTenantScopedPrivilegesByRoleID(ctx, tenantID, roleID)
+	}
+	if _, ok := s.repo.(ScopedPrivilegeRepository); ok {
+		return nil, fmt.Errorf("rbac: scoped grants of role %s in tenant %s: %w", roleID, tenantID, ErrTenantsNotSupported)
+	}
+	return nil, nil
+}
+
+// fetchTimedGrants returns the time-bounded grants of a role within a tenant, nil when
+// the repository stores none. Like scoped grants, they must not be left out silently.
+func (s *rbacService) fetchTimedGrants(ctx context.Context, tenantID, roleID string) ([]PrivilegeGrant, error) {
+	if tenantID == "" {
+		if timedRepo, ok := s.repo.(TimedPrivilegeRepository); ok {
+			return timedRepo.FetchTimedPrivilegesByRoleID(ctx, roleID)
+		}
+		return nil, nil
+	}
+
+	if timedRepo, ok := s.repo.(TenantTimedPrivilegeRepository); ok {
+		return timedRepo.FetchTenantTimedPrivilegesByRoleID(ctx, tenantID, roleID)
+	}
+	if _, ok := s.repo.(TimedPrivilegeRepository); ok {
+		return nil, fmt.Errorf("rbac: time-bounded grants of role %s in tenant %s: %w", roleID, tenantID, ErrTenantsNotSupported)
+	}
+	return nil, nil
+}
+
 // startPeriodicRefresh is a private method that refreshes role privileges at regular intervals
-func (s *rbacService) startPeriodicRefresh(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		// Get all role IDs from cache
-		roleIDs := s.cache.GetAllKeys()
-
-		// Refresh each role's privileges
-		for _, roleID := range roleIDs {
-			ctx := context.Background()
-			_, err := s.loadRolePrivileges(ctx, roleID)
-			if err != nil {
-				// Log error but continue with other roles
-				s.logger.Errorf("Error refreshing privileges for role %s: %v", roleID, err)
-			}
+// until ctx is cancelled
+func (s *rbacService) startPeriodicRefresh(ctx context.Context, interval time.Duration) {
+	timer := time.NewTimer(s.nextRefreshDelay(interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		// Expired entries are dropped rather than reloaded, so roles nobody asks
+		// for any more leave the cache
+		s.refreshCache(ctx, s.cachedKeys(ctx, ""))
+
+		for _, tenantID := range s.tenants.GetAllTenants() {
+			s.refreshCache(InjectTenant(ctx, tenantID), s.cachedKeys(ctx, tenantID))
 		}
+
+		timer.Reset(s.nextRefreshDelay(interval))
 	}
 }
 
-// GetRolePrivileges returns the privileges for a given role ID
-// It first checks the cache, if not found, it loads the privileges from the database
-// and then caches them
+// refreshCache reloads the given roles of the tenant in ctx, up to
+// refreshConcurrency batches at a time. Roles are fetched in batches when the
+// repository implements BatchPrivilegeRepository, one by one otherwise, unless
+// streamsRefresh decides to read them in a single pass instead.
+func (s *rbacService) refreshCache(ctx context.Context, roleIDs []string) {
+	if s.streamsRefresh(ctx, roleIDs) {
+		s.refreshAll(ctx, roleIDs)
+		return
+	}
+
+	s.refreshConcurrently(ctx, s.refreshBatches(ctx, roleIDs), s.refreshBatch)
+}
+
+// refreshConcurrently calls refresh for every unit of roles, up to
+// refreshConcurrency at a time, and starts no more once ctx is done
+func (s *rbacService) refreshConcurrently(ctx context.Context, units [][]string, refresh func(ctx context.Context, roleIDs []string)) {
+	concurrency := max(s.refreshConcurrency, 1)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, unit := range units {
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			refresh(ctx, unit)
+		}()
+	}
+	wg.Wait()
+}
+
+// refreshRole reloads one role, logging a failure
+func (s *rbacService) refreshRole(ctx context.Context, roleID string, fetch ownPrivilegesFunc) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	_, err := s.loadRolePrivilegesFrom(ctx, roleID, fetch)
+	if err != nil {
+		// Log error but continue with other roles
+		s.logger.Errorf("Error refreshing privileges for role %s: %v", roleID, err)
+	}
+}
+
+// GetRolePrivileges returns the privileges for a given role ID as a new map, which
+// the caller may modify. See GetRolePrivilegeSet.
 func (s *rbacService) GetRolePrivileges(ctx context.Context, roleID string) (map[string]bool, error) {
 
-	privileges, exist := s.cache.Get(roleID)
+	privileges, err := s.GetRolePrivilegeSet(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return privileges.ToMap(), nil
+}
+
+// GetRolePrivilegeSet returns the privileges for a given role ID
+// It first checks the cache, if not found, it loads the privileges from the database
+// and then caches them. Concurrent misses for the same role share one load.
+// With WithStaleWhileRevalidate a stale entry is returned while it is reloaded.
+// A tenant injected with InjectTenant scopes the lookup.
+func (s *rbacService) GetRolePrivilegeSet(ctx context.Context, roleID string) (PrivilegeSet, error) {
+
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	entry, exist := s.cachedEntry(ctx, tenantID, roleID)
 	if !exist {
-		var err error
-		privileges, err = s.loadRolePrivileges(ctx, roleID)
-		if err != nil {
-			return nil, err
+		if s.negative.contains(tenantRoleKey(tenantID, roleID)) {
+			s.stats.negativeHits.Add(1)
+			return PrivilegeSet{}, &RoleNotFoundError{RoleID: roleID}
 		}
-		return privileges, nil
+
+		s.stats.misses.Add(1)
+		return s.loadRolePrivilegesOnce(ctx, roleID)
 	}
 
-	return privileges, nil
+	s.stats.hits.Add(1)
+	if s.isStale(entry.LoadedAt) {
+		s.stats.staleHits.Add(1)
+		s.revalidate(ctx, tenantID, roleID)
+	}
+	return entry.Privileges, nil
 }
 
-// HasPrivilege checks if a given role has a specific privilege
+// HasPrivilege checks if a given role has a specific privilege and it is not explicitly denied
 func (s *rbacService) HasPrivilege(ctx context.Context, roleID string, privilege string) (bool, error) {
 
-	privileges, err := s.GetRolePrivileges(ctx, roleID)
+	matcher, err := s.getRoleMatcher(ctx, roleID)
 	if err != nil {
 		return false, err
 	}
 
-	return privileges[privilege], nil
+	return matcher.has(privilege), nil
+}
+
+// HasPrivilegeHandle is HasPrivilege for a privilege resolved with ResolvePrivilege.
+// With the in-process cache a cached role takes one lookup and a bit test on its
+// compiled bitset.
+func (s *rbacService) HasPrivilegeHandle(ctx context.Context, roleID string, privilege PrivilegeHandle) (bool, error) {
+
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	if local, ok := s.localCacheFor(tenantID); ok {
+		if compiled, loadedAt, ok := local.compiledEntry(roleID); ok {
+			s.stats.hits.Add(1)
+			if s.isStale(loadedAt) {
+				s.stats.staleHits.Add(1)
+				s.revalidate(ctx, tenantID, roleID)
+			}
+			return compiled.has(privilege), nil
+		}
+	}
+
+	privileges, err := s.GetRolePrivilegeSet(ctx, roleID)
+	if err != nil {
+		return false, err
+	}
+
+	// Another backend, or the role was just loaded; check what we got
+	return newPrivilegeMatcher(privileges.codes).has(privilege.code), nil
 }
 
 // HasAnyPrivilege checks if a given role has any of the specified privileges
 func (s *rbacService) HasAnyPrivilege(ctx context.Context, roleID string, privilegeCodes ...string) (bool, error) {
 
-	privileges, err := s.GetRolePrivileges(ctx, roleID)
+	matcher, err := s.getRoleMatcher(ctx, roleID)
 	if err != nil {
 		return false, err
 	}
 
 	for _, code := range privilegeCodes {
-		if privileges[code] {
+		if matcher.has(code) {
 			return true, nil
 		}
 	}
@@ -120,21 +490,200 @@ func (s *rbacService) HasAnyPrivilege(ctx context.Context, roleID string, privil
 	return false, nil
 }
 
-// SetNewRolePrivileges sets the privileges for a new role
+// HasAllPrivileges checks if a given role has every one of the specified privileges
+func (s *rbacService) HasAllPrivileges(ctx context.Context, roleID string, privilegeCodes ...string) (bool, error) {
+	return s.MeetsRequirement(ctx, roleID, AllOf(privilegeCodes...))
+}
+
+// MeetsRequirement checks if a given role satisfies a compound requirement
+func (s *rbacService) MeetsRequirement(ctx context.Context, roleID string, req *Requirement) (bool, error) {
+
+	matcher, err := s.getRoleMatcher(ctx, roleID)
+	if err != nil {
+		return false, err
+	}
+
+	return req.Evaluate(matcher.has), nil
+}
+
+// getRoleMatcher returns the compiled privilege matcher for a given role ID,
+// loading the role's privileges first if needed
+func (s *rbacService) getRoleMatcher(ctx context.Context, roleID string) (*privilegeMatcher, error) {
+
+	privileges, err := s.GetRolePrivilegeSet(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The in-process cache keeps compiled matchers next to the privileges
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	if local, ok := s.localCacheFor(tenantID); ok {
+		if matcher, ok := local.matcher(roleID); ok {
+			return matcher, nil
+		}
+	}
+
+	// Another backend, or the entry was evicted in the meantime; compile what we loaded
+	return newPrivilegeMatcher(privileges.codes), nil
+}
+
+// SetNewRolePrivileges sets the privileges granted directly to a role. With a
+// PrivilegeWriter they are persisted first, and the cache is only updated once that
+// succeeded. The cached entry is built like any load, so inherited privileges and
+// denies, scoped grants and time-bounded grants still apply to the role.
+// With an InvalidationBus the other instances drop the role, reloading it on next use.
 func (s *rbacService) SetNewRolePrivileges(ctx context.Context, roleID string, privileges []string) error {
 
-	privilegesMap := make(map[string]bool)
-	for _, privilege := range privileges {
-		privilegesMap[privilege] = true
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	if s.writer != nil {
+		if err := s.checkWritable(tenantID); err != nil {
+			return err
+		}
+		if err := s.writer.ReplaceRolePrivileges(ctx, roleID, privileges); err != nil {
+			return err
+		}
 	}
 
-	s.cache.Set(roleID, privilegesMap)
+	// The new list replaces what the repository holds for the role itself only
+	own := NewPrivilegeSet(privileges...).ToMap()
+	fetch := func(ctx context.Context, role string) (map[string]bool, time.Time, error) {
+		if role != roleID {
+			return s.fetchOwnPrivileges(ctx, role)
+		}
+		return s.withExtraGrants(ctx, roleID, own)
+	}
 
-	return nil
+	s.negative.remove(tenantRoleKey(tenantID, roleID))
+	if _, err := s.loadRolePrivilegesFrom(ctx, roleID, fetch); err != nil {
+		// Whatever is cached no longer matches the store
+		s.invalidateRoleLocal(ctx, tenantID, roleID)
+		return errors.Join(err, s.publishInvalidation(ctx, tenantID, roleID))
+	}
+	s.invalidateDependents(tenantID, roleID)
+
+	return s.publishInvalidation(ctx, tenantID, roleID)
 }
 
-// DeleteRolePrivileges removes a role's privileges from the cache
+// DeleteRolePrivileges removes a role's privileges from the cache, together with
+// every cached role inheriting from it, on every instance sharing the InvalidationBus.
+// With a PrivilegeWriter the privileges are revoked in the store first.
 func (s *rbacService) DeleteRolePrivileges(ctx context.Context, roleID string) error {
-	s.cache.Delete(roleID)
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	if s.writer != nil {
+		if err := s.checkWritable(tenantID); err != nil {
+			return err
+		}
+		if err := s.writer.DeleteRolePrivileges(ctx, roleID); err != nil {
+			return err
+		}
+	}
+
+	s.invalidateRoleLocal(ctx, tenantID, roleID)
+	return s.publishInvalidation(ctx, tenantID, roleID)
+}
+
+// checkWritable reports whether changes to roles of a tenant can be written
+// through; a PrivilegeWriter only knows roles outside any tenant
+func (s *rbacService) checkWritable(tenantID string) error {
+	if tenantID != "" {
+		return fmt.Errorf("rbac: writing privileges in tenant %q: %w", tenantID, ErrTenantsNotSupported)
+	}
 	return nil
 }
+
+// GetRolesPrivileges returns the union of the privileges of all given roles as a
+// new map, which the caller may modify. See GetRolesPrivilegeSet.
+func (s *rbacService) GetRolesPrivileges(ctx context.Context, roleIDs []string) (map[string]bool, error) {
+
+	union, err := s.GetRolesPrivilegeSet(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return union.ToMap(), nil
+}
+
+// GetRolesPrivilegeSet returns the union of the privileges of all given roles.
+// Each role is looked up (and cached) individually.
+func (s *rbacService) GetRolesPrivilegeSet(ctx context.Context, roleIDs []string) (PrivilegeSet, error) {
+
+	var union PrivilegeSet
+	for _, roleID := range roleIDs {
+		privileges, err := s.GetRolePrivilegeSet(ctx, roleID)
+		if err != nil {
+			return PrivilegeSet{}, err
+		}
+		union = union.Union(privileges)
+	}
+
+	return union, nil
+}
+
+// HasPrivilegeInRoles checks if any of the given roles has a specific privilege
+func (s *rbacService) HasPrivilegeInRoles(ctx context.Context, roleIDs []string, privilege string) (bool, error) {
+	return s.HasAnyPrivilegeInRoles(ctx, roleIDs, privilege)
+}
+
+// HasAnyPrivilegeInRoles checks if the given roles together hold any of the specified privileges.
+// A privilege denied by one role is denied even if another role allows it.
+func (s *rbacService) HasAnyPrivilegeInRoles(ctx context.Context, roleIDs []string, privilegeCodes ...string) (bool, error) {
+
+	matchers, err := s.getRolesMatchers(ctx, roleIDs)
+	if err != nil {
+		return false, err
+	}
+
+	for _, code := range privilegeCodes {
+		if matchers.has(code) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// MeetsRequirementInRoles checks if the given roles together satisfy a compound requirement
+func (s *rbacService) MeetsRequirementInRoles(ctx context.Context, roleIDs []string, req *Requirement) (bool, error) {
+
+	matchers, err := s.getRolesMatchers(ctx, roleIDs)
+	if err != nil {
+		return false, err
+	}
+
+	return req.Evaluate(matchers.has), nil
+}
+
+// getRolesMatchers returns the compiled privilege matchers of several roles
+func (s *rbacService) getRolesMatchers(ctx context.Context, roleIDs []string) (privilegeMatchers, error) {
+
+	matchers := make(privilegeMatchers, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		matcher, err := s.getRoleMatcher(ctx, roleID)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	return matchers, nil
+}
+
+// GetUserRoleIDs returns the role IDs assigned to a user
+func (s *rbacService) GetUserRoleIDs(ctx context.Context, userID string) ([]string, error) {
+	if s.userRoles == nil {
+		return nil, ErrNoUserRoleRepository
+	}
+
+	return s.userRoles.FetchRoleIDsByUserID(ctx, userID)
+}
+
+// HasUserPrivilege checks if any of the user's roles has a specific privilege
+func (s *rbacService) HasUserPrivilege(ctx context.Context, userID string, privilege string) (bool, error) {
+
+	roleIDs, err := s.GetUserRoleIDs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return s.HasPrivilegeInRoles(ctx, roleIDs, privilege)
+}
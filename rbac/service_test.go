@@ -0,0 +1,812 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type mockPrivilegeRepository struct {
+	privileges map[string]map[string]bool
+	err        error
+	missing    map[string]bool
+	calls      map[string]int
+}
+
+func (m *mockPrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	if m.calls == nil {
+		m.calls = make(map[string]int)
+	}
+	m.calls[roleID]++
+
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.missing[roleID] {
+		return nil, &RoleNotFoundError{RoleID: roleID}
+	}
+
+	result := make(map[string]bool)
+	for code, granted := range m.privileges[roleID] {
+		result[code] = granted
+	}
+	return result, nil
+}
+
+type mockUserRoleRepository struct {
+	roles map[string][]string
+}
+
+func (m *mockUserRoleRepository) FetchRoleIDsByUserID(ctx context.Context, userID string) ([]string, error) {
+	return m.roles[userID], nil
+}
+
+func newTestService(privileges map[string]map[string]bool, opts ...Option) (*rbacService, *mockPrivilegeRepository) {
+	repo := &mockPrivilegeRepository{privileges: privileges}
+	return NewRBACService(repo, 0, nil, opts...).(*rbacService), repo
+}
+
+// localCache returns the default in-process cache of roles outside any tenant
+func (s *rbacService) localCache() *RolePrivilegesCache {
+	return s.cache.(*RolePrivilegesCache)
+}
+
+func TestRBACService_GetRolesPrivileges(t *testing.T) {
+	privileges := map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+	}
+
+	tests := []struct {
+		name    string
+		roleIDs []string
+		want    map[string]bool
+	}{
+		{
+			name:    "union of two roles",
+			roleIDs: []string{"viewer", "editor"},
+			want:    map[string]bool{"read:users": true, "write:users": true},
+		},
+		{
+			name:    "single role",
+			roleIDs: []string{"viewer"},
+			want:    map[string]bool{"read:users": true},
+		},
+		{
+			name:    "no roles",
+			roleIDs: nil,
+			want:    map[string]bool{},
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(privileges)
+			got, err := svc.GetRolesPrivileges(context.Background(), tt.roleIDs)
+			if err != nil {
+				t.Fatalf("GetRolesPrivileges() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRolesPrivileges() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBACService_GetRolesPrivileges_CachesPerRole(t *testing.T) {
+	svc, repo := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.GetRolesPrivileges(context.Background(), []string{"viewer", "editor"}); err != nil {
+			t.Fatalf("GetRolesPrivileges() error = %v", err)
+		}
+	}
+
+	if repo.calls["viewer"] != 1 || repo.calls["editor"] != 1 {
+		t.Errorf("repository should be hit once per role, got %v", repo.calls)
+	}
+}
+
+func TestRBACService_HasUserPrivilege(t *testing.T) {
+	userRoles := &mockUserRoleRepository{roles: map[string][]string{
+		"alice": {"viewer", "editor"},
+		"bob":   {"viewer"},
+	}}
+	svc, _ := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+	}, WithUserRoleRepository(userRoles))
+
+	tests := []struct {
+		name      string
+		userID    string
+		privilege string
+		want      bool
+	}{
+		{name: "privilege from second role", userID: "alice", privilege: "write:users", want: true},
+		{name: "privilege from first role", userID: "alice", privilege: "read:users", want: true},
+		{name: "missing privilege", userID: "bob", privilege: "write:users", want: false},
+		{name: "user without roles", userID: "carol", privilege: "read:users", want: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.HasUserPrivilege(context.Background(), tt.userID, tt.privilege)
+			if err != nil {
+				t.Fatalf("HasUserPrivilege() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasUserPrivilege() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBACService_GetUserRoleIDs_NoRepository(t *testing.T) {
+	svc, _ := newTestService(nil)
+
+	_, err := svc.GetUserRoleIDs(context.Background(), "alice")
+	if !errors.Is(err, ErrNoUserRoleRepository) {
+		t.Errorf("GetUserRoleIDs() error = %v, want %v", err, ErrNoUserRoleRepository)
+	}
+}
+
+func TestGetRoleIDsFromContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   []string
+		wantOk bool
+	}{
+		{
+			name:   "multiple roles",
+			ctx:    InjectContextWithRoles(context.Background(), []string{"viewer", "editor"}, "u1", nil),
+			want:   []string{"editor", "viewer"},
+			wantOk: true,
+		},
+		{
+			name:   "single role fallback",
+			ctx:    InjectContext(context.Background(), "viewer", "u1", nil),
+			want:   []string{"viewer"},
+			wantOk: true,
+		},
+		{
+			name:   "no roles",
+			ctx:    context.Background(),
+			want:   nil,
+			wantOk: false,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GetRoleIDsFromContext(tt.ctx)
+			sort.Strings(got)
+			if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRoleIDsFromContext() got = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+type mockRoleParentRepository struct {
+	parents map[string][]string
+}
+
+func (m *mockRoleParentRepository) FetchParentRoleIDs(ctx context.Context, roleID string) ([]string, error) {
+	return m.parents[roleID], nil
+}
+
+func TestRBACService_HasPrivilege_Inheritance(t *testing.T) {
+	parents := &mockRoleParentRepository{parents: map[string][]string{
+		"editor": {"viewer"},
+		"admin":  {"editor"},
+	}}
+	svc, _ := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+		"editor": {"write:users": true},
+		"admin":  {"delete:users": true},
+	}, WithRoleParentRepository(parents))
+
+	tests := []struct {
+		name      string
+		roleID    string
+		privilege string
+		want      bool
+	}{
+		{name: "own privilege", roleID: "admin", privilege: "delete:users", want: true},
+		{name: "inherited from parent", roleID: "admin", privilege: "write:users", want: true},
+		{name: "inherited transitively", roleID: "admin", privilege: "read:users", want: true},
+		{name: "not inherited downwards", roleID: "viewer", privilege: "write:users", want: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.HasPrivilege(context.Background(), tt.roleID, tt.privilege)
+			if err != nil {
+				t.Fatalf("HasPrivilege() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasPrivilege() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBACService_GetRolePrivileges_Cycle(t *testing.T) {
+	parents := &mockRoleParentRepository{parents: map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"a"},
+	}}
+	svc, _ := newTestService(nil, WithRoleParentRepository(parents))
+
+	_, err := svc.GetRolePrivileges(context.Background(), "a")
+	if !errors.Is(err, ErrRoleCycle) {
+		t.Fatalf("GetRolePrivileges() error = %v, want %v", err, ErrRoleCycle)
+	}
+
+	var cycleErr *RoleCycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("GetRolePrivileges() error should be a *RoleCycleError")
+	}
+	if want := []string{"a", "b", "c", "a"}; !reflect.DeepEqual(cycleErr.Path, want) {
+		t.Errorf("RoleCycleError.Path = %v, want %v", cycleErr.Path, want)
+	}
+	if _, cached := svc.localCache().Get("a"); cached {
+		t.Errorf("role with cyclic inheritance should not be cached")
+	}
+}
+
+func TestRBACService_SetNewRolePrivileges_InvalidatesDependents(t *testing.T) {
+	parents := &mockRoleParentRepository{parents: map[string][]string{
+		"editor": {"viewer"},
+		"admin":  {"editor"},
+	}}
+	svc, _ := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+	}, WithRoleParentRepository(parents))
+
+	ctx := context.Background()
+	for _, roleID := range []string{"viewer", "editor", "admin"} {
+		if _, err := svc.GetRolePrivileges(ctx, roleID); err != nil {
+			t.Fatalf("GetRolePrivileges(%s) error = %v", roleID, err)
+		}
+	}
+
+	if err := svc.SetNewRolePrivileges(ctx, "viewer", []string{"read:reports"}); err != nil {
+		t.Fatalf("SetNewRolePrivileges() error = %v", err)
+	}
+
+	for _, roleID := range []string{"editor", "admin"} {
+		if _, cached := svc.localCache().Get(roleID); cached {
+			t.Errorf("role %s should be invalidated after its ancestor changed", roleID)
+		}
+	}
+	if _, cached := svc.localCache().Get("viewer"); !cached {
+		t.Errorf("changed role itself should stay cached")
+	}
+}
+
+func TestRBACService_SetNewRolePrivileges_KeepsEffectivePrivileges(t *testing.T) {
+	parents := &mockRoleParentRepository{parents: map[string][]string{
+		"editor": {"viewer"},
+	}}
+	repo := &mockTimedPrivilegeRepository{
+		mockPrivilegeRepository: mockPrivilegeRepository{privileges: map[string]map[string]bool{
+			"viewer": {"read:posts": true, "!delete:posts": true},
+			"editor": {"write:posts": true},
+		}},
+		grants: map[string][]PrivilegeGrant{
+			"editor": {{Privilege: "review:posts", ValidUntil: time.Now().Add(time.Hour)}},
+		},
+	}
+	svc := NewRBACService(repo, 0, nil, WithRoleParentRepository(parents)).(*rbacService)
+	ctx := context.Background()
+
+	if _, err := svc.GetRolePrivileges(ctx, "editor"); err != nil {
+		t.Fatalf("GetRolePrivileges() error = %v", err)
+	}
+	if err := svc.SetNewRolePrivileges(ctx, "editor", []string{"publish:posts", "delete:posts"}); err != nil {
+		t.Fatalf("SetNewRolePrivileges() error = %v", err)
+	}
+
+	tests := []struct {
+		privilege string
+		want      bool
+	}{
+		{privilege: "publish:posts", want: true},
+		{privilege: "write:posts", want: false},
+		{privilege: "read:posts", want: true},    // inherited from viewer
+		{privilege: "delete:posts", want: false}, // still denied by viewer
+		{privilege: "review:posts", want: true},  // time-bounded grant
+	}
+	for _, tt := range tests {
+		if got, err := svc.HasPrivilege(ctx, "editor", tt.privilege); err != nil || got != tt.want {
+			t.Errorf("HasPrivilege(%s) got = %v, %v, want %v", tt.privilege, got, err, tt.want)
+		}
+	}
+}
+
+func TestRBACService_HasPrivilege_Deny(t *testing.T) {
+	parents := &mockRoleParentRepository{parents: map[string][]string{
+		"contractor": {"employee"},
+	}}
+	svc, _ := newTestService(map[string]map[string]bool{
+		"employee":   {"export:data": true, "read:data": true},
+		"contractor": {"!export:data": true},
+		"exporter":   {"export:*": true},
+	}, WithRoleParentRepository(parents))
+
+	tests := []struct {
+		name       string
+		roleIDs    []string
+		privileges []string
+		want       bool
+	}{
+		{name: "inherited allow", roleIDs: []string{"employee"}, privileges: []string{"export:data"}, want: true},
+		{name: "deny overrides inherited allow", roleIDs: []string{"contractor"}, privileges: []string{"export:data"}, want: false},
+		{name: "other inherited privileges stay", roleIDs: []string{"contractor"}, privileges: []string{"read:data"}, want: true},
+		{name: "deny overrides allow from another role", roleIDs: []string{"exporter", "contractor"}, privileges: []string{"export:data"}, want: false},
+		{name: "any-of skips denied privilege", roleIDs: []string{"contractor"}, privileges: []string{"export:data", "read:data"}, want: true},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.HasAnyPrivilegeInRoles(context.Background(), tt.roleIDs, tt.privileges...)
+			if err != nil {
+				t.Fatalf("HasAnyPrivilegeInRoles() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasAnyPrivilegeInRoles() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopedPrivilege(t *testing.T) {
+	tests := []struct {
+		name           string
+		a, b           [2]string // resource type and ID
+		wantSameKey    bool
+		wantKeyOfFirst string
+	}{
+		{name: "plain", a: [2]string{"project", "42"}, b: [2]string{"project", "42"}, wantSameKey: true, wantKeyOfFirst: "edit:projects@project/42"},
+		{name: "slash", a: [2]string{"a", "b/c"}, b: [2]string{"a/b", "c"}, wantKeyOfFirst: "edit:projects@a/b%2Fc"},
+		{name: "colon", a: [2]string{"doc", "x:y"}, b: [2]string{"doc:x", "y"}, wantKeyOfFirst: "edit:projects@doc/x%3Ay"},
+		{name: "at", a: [2]string{"user", "a@b"}, b: [2]string{"user@a", "b"}, wantKeyOfFirst: "edit:projects@user/a%40b"},
+		{name: "escaped text", a: [2]string{"a", "%2F"}, b: [2]string{"a", "/"}, wantKeyOfFirst: "edit:projects@a/%252F"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := ScopedPrivilege("edit:projects", tt.a[0], tt.a[1])
+			b := ScopedPrivilege("edit:projects", tt.b[0], tt.b[1])
+			if (a == b) != tt.wantSameKey {
+				t.Errorf("keys %q and %q: same = %v, want %v", a, b, a == b, tt.wantSameKey)
+			}
+			if a != tt.wantKeyOfFirst {
+				t.Errorf("ScopedPrivilege() = %q, want %q", a, tt.wantKeyOfFirst)
+			}
+		})
+	}
+}
+
+type mockScopedPrivilegeRepository struct {
+	mockPrivilegeRepository
+	grants map[string][]ScopedGrant
+}
+
+func (m *mockScopedPrivilegeRepository) FetchScopedPrivilegesByRoleID(ctx context.Context, roleID string) ([]ScopedGrant, error) {
+	return m.grants[roleID], nil
+}
+
+func TestRBACService_HasPrivilegeOn(t *testing.T) {
+	repo := &mockScopedPrivilegeRepository{
+		mockPrivilegeRepository: mockPrivilegeRepository{privileges: map[string]map[string]bool{
+			"manager": {"edit:projects": true},
+			"auditor": {"read:projects": true},
+		}},
+		grants: map[string][]ScopedGrant{
+			"member":  {{Privilege: "edit:projects", ResourceType: "project", ResourceID: "42"}},
+			"auditor": {{Privilege: "!read:projects", ResourceType: "project", ResourceID: "7"}},
+			"guest":   {{Privilege: "read:projects", ResourceType: "project", ResourceID: "*"}},
+		},
+	}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+
+	tests := []struct {
+		name       string
+		roleID     string
+		privilege  string
+		resourceID string
+		want       bool
+	}{
+		{name: "scoped grant on its resource", roleID: "member", privilege: "edit:projects", resourceID: "42", want: true},
+		{name: "scoped grant on another resource", roleID: "member", privilege: "edit:projects", resourceID: "43", want: false},
+		{name: "global grant implies every resource", roleID: "manager", privilege: "edit:projects", resourceID: "43", want: true},
+		{name: "scoped deny overrides global grant", roleID: "auditor", privilege: "read:projects", resourceID: "7", want: false},
+		{name: "scoped deny leaves other resources", roleID: "auditor", privilege: "read:projects", resourceID: "8", want: true},
+		{name: "wildcard id is literal", roleID: "guest", privilege: "read:projects", resourceID: "*", want: true},
+		{name: "wildcard id covers no other resource", roleID: "guest", privilege: "read:projects", resourceID: "43", want: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.HasPrivilegeOn(context.Background(), tt.roleID, tt.privilege, "project", tt.resourceID)
+			if err != nil {
+				t.Fatalf("HasPrivilegeOn() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasPrivilegeOn() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if ok, _ := svc.HasPrivilege(context.Background(), "member", "edit:projects"); ok {
+		t.Errorf("a scoped grant must not imply the global privilege")
+	}
+}
+
+type mockTenantPrivilegeRepository struct {
+	mockPrivilegeRepository
+	tenants map[string]map[string]map[string]bool
+}
+
+func (m *mockTenantPrivilegeRepository) FetchTenantPrivilegesByRoleID(ctx context.Context, tenantID string, roleID string) (map[string]bool, error) {
+	result := make(map[string]bool)
+	for code, granted := range m.tenants[tenantID][roleID] {
+		result[code] = granted
+	}
+	return result, nil
+}
+
+func TestRBACService_Tenants(t *testing.T) {
+	repo := &mockTenantPrivilegeRepository{tenants: map[string]map[string]map[string]bool{
+		"acme":   {"admin": {"delete:users": true}},
+		"globex": {"admin": {"read:users": true}},
+	}}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		tenantID  string
+		privilege string
+		want      bool
+	}{
+		{name: "granted in own tenant", tenantID: "acme", privilege: "delete:users", want: true},
+		{name: "not leaked from other tenant", tenantID: "globex", privilege: "delete:users", want: false},
+		{name: "granted in other tenant", tenantID: "globex", privilege: "read:users", want: true},
+		{name: "unknown tenant", tenantID: "initech", privilege: "read:users", want: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.HasTenantPrivilege(ctx, tt.tenantID, "admin", tt.privilege)
+			if err != nil {
+				t.Fatalf("HasTenantPrivilege() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasTenantPrivilege() got = %v, want %v", got, tt.want)
+			}
+
+			// The tenant in the context scopes the regular API the same way
+			got, err = svc.HasPrivilege(InjectTenant(ctx, tt.tenantID), "admin", tt.privilege)
+			if err != nil || got != tt.want {
+				t.Errorf("HasPrivilege() with tenant context got = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	if err := svc.InvalidateTenant(ctx, "acme"); err != nil {
+		t.Fatalf("InvalidateTenant() error = %v", err)
+	}
+	if _, ok := svc.tenants.Get("acme", "admin"); ok {
+		t.Errorf("InvalidateTenant() should drop the tenant's cache")
+	}
+	if _, ok := svc.tenants.Get("globex", "admin"); !ok {
+		t.Errorf("InvalidateTenant() should keep other tenants cached")
+	}
+}
+
+func TestRBACService_Tenants_NotSupported(t *testing.T) {
+	svc, _ := newTestService(nil)
+
+	_, err := svc.HasTenantPrivilege(context.Background(), "acme", "admin", "read:users")
+	if !errors.Is(err, ErrTenantsNotSupported) {
+		t.Errorf("HasTenantPrivilege() error = %v, want %v", err, ErrTenantsNotSupported)
+	}
+}
+
+type notFoundTenantRepository struct {
+	mockPrivilegeRepository
+}
+
+func (m *notFoundTenantRepository) FetchTenantPrivilegesByRoleID(ctx context.Context, tenantID string, roleID string) (map[string]bool, error) {
+	return nil, &RoleNotFoundError{RoleID: roleID}
+}
+
+func TestRBACService_Tenants_NoCacheForLookupsOnly(t *testing.T) {
+	svc := NewRBACService(&notFoundTenantRepository{}, 0, nil).(*rbacService)
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		tenantID := fmt.Sprintf("tenant-%d", i)
+		if _, err := svc.HasTenantPrivilege(ctx, tenantID, "admin", "read:users"); !errors.Is(err, ErrRoleNotFound) {
+			t.Fatalf("HasTenantPrivilege() error = %v, want %v", err, ErrRoleNotFound)
+		}
+		if err := svc.InvalidateTenantRole(ctx, tenantID, "admin"); err != nil {
+			t.Fatalf("InvalidateTenantRole() error = %v", err)
+		}
+	}
+	if tenants := svc.tenants.GetAllTenants(); len(tenants) != 0 {
+		t.Errorf("lookups of unknown roles should not create tenant caches, got %d", len(tenants))
+	}
+}
+
+func TestRBACService_Tenants_EmptyCacheDropped(t *testing.T) {
+	repo := &mockTenantPrivilegeRepository{tenants: map[string]map[string]map[string]bool{
+		"acme": {"admin": {"delete:users": true}},
+	}}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+	ctx := context.Background()
+
+	if _, err := svc.GetTenantRolePrivileges(ctx, "acme", "admin"); err != nil {
+		t.Fatalf("GetTenantRolePrivileges() error = %v", err)
+	}
+	if tenants := svc.tenants.GetAllTenants(); len(tenants) != 1 {
+		t.Fatalf("expected the tenant to be cached, got %v", tenants)
+	}
+
+	if err := svc.InvalidateTenantRole(ctx, "acme", "admin"); err != nil {
+		t.Fatalf("InvalidateTenantRole() error = %v", err)
+	}
+	if tenants := svc.tenants.GetAllTenants(); len(tenants) != 0 {
+		t.Errorf("a tenant without cached roles should be dropped, got %v", tenants)
+	}
+}
+
+type mockTenantRoleParentRepository struct {
+	mockRoleParentRepository
+	tenants map[string]map[string][]string
+}
+
+func (m *mockTenantRoleParentRepository) FetchTenantParentRoleIDs(ctx context.Context, tenantID string, roleID string) ([]string, error) {
+	return m.tenants[tenantID][roleID], nil
+}
+
+func TestRBACService_Tenants_Inheritance(t *testing.T) {
+	repo := &mockTenantPrivilegeRepository{tenants: map[string]map[string]map[string]bool{
+		"acme":   {"viewer": {"read:users": true}, "editor": {"write:users": true}, "admin": {}},
+		"globex": {"viewer": {"read:users": true}, "editor": {"write:users": true}, "admin": {}},
+	}}
+	parents := &mockTenantRoleParentRepository{tenants: map[string]map[string][]string{
+		"acme":   {"admin": {"editor"}, "editor": {"viewer"}},
+		"globex": {"admin": {"viewer"}},
+	}}
+	svc := NewRBACService(repo, 0, nil, WithRoleParentRepository(parents)).(*rbacService)
+	ctx := context.Background()
+
+	tests := []struct {
+		tenantID  string
+		privilege string
+		want      bool
+	}{
+		{tenantID: "acme", privilege: "write:users", want: true},
+		{tenantID: "acme", privilege: "read:users", want: true},
+		{tenantID: "globex", privilege: "write:users", want: false},
+		{tenantID: "globex", privilege: "read:users", want: true},
+	}
+
+	for _, tt := range tests {
+		got, err := svc.HasTenantPrivilege(ctx, tt.tenantID, "admin", tt.privilege)
+		if err != nil {
+			t.Fatalf("HasTenantPrivilege(%s, %s) error = %v", tt.tenantID, tt.privilege, err)
+		}
+		if got != tt.want {
+			t.Errorf("HasTenantPrivilege(%s, %s) got = %v, want %v", tt.tenantID, tt.privilege, got, tt.want)
+		}
+	}
+}
+
+func TestRBACService_Tenants_InheritanceNotSupported(t *testing.T) {
+	repo := &mockTenantPrivilegeRepository{tenants: map[string]map[string]map[string]bool{
+		"acme": {"admin": {"delete:users": true}},
+	}}
+	parents := &mockRoleParentRepository{parents: map[string][]string{"admin": {"viewer"}}}
+	svc := NewRBACService(repo, 0, nil, WithRoleParentRepository(parents)).(*rbacService)
+
+	_, err := svc.HasTenantPrivilege(context.Background(), "acme", "admin", "delete:users")
+	if !errors.Is(err, ErrTenantsNotSupported) {
+		t.Errorf("HasTenantPrivilege() error = %v, want %v", err, ErrTenantsNotSupported)
+	}
+}
+
+type mockTimedPrivilegeRepository struct {
+	mockPrivilegeRepository
+	grants map[string][]PrivilegeGrant
+}
+
+func (m *mockTimedPrivilegeRepository) FetchTimedPrivilegesByRoleID(ctx context.Context, roleID string) ([]PrivilegeGrant, error) {
+	return m.grants[roleID], nil
+}
+
+func TestRBACService_HasPrivilege_TimeBounded(t *testing.T) {
+	now := time.Now()
+	repo := &mockTimedPrivilegeRepository{grants: map[string][]PrivilegeGrant{
+		"auditor": {
+			{Privilege: "read:ledger", ValidFrom: now.Add(-time.Hour), ValidUntil: now.Add(time.Hour)},
+			{Privilege: "read:payroll", ValidUntil: now.Add(-time.Minute)},
+			{Privilege: "close:quarter", ValidFrom: now.Add(time.Hour)},
+		},
+	}}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+
+	tests := []struct {
+		name      string
+		privilege string
+		want      bool
+	}{
+		{name: "inside window", privilege: "read:ledger", want: true},
+		{name: "window ended", privilege: "read:payroll", want: false},
+		{name: "window not started", privilege: "close:quarter", want: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.HasPrivilege(context.Background(), "auditor", tt.privilege)
+			if err != nil {
+				t.Fatalf("HasPrivilege() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasPrivilege() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRBACService_ScheduleExpiry_KeepsReplacedTimer(t *testing.T) {
+	svc, _ := newTestService(nil)
+	defer svc.Close()
+	key := tenantRoleKey("", "viewer")
+
+	// The first timer fires while the lock is held, and a reload replaces it
+	// before its callback gets the lock
+	svc.scheduleExpiry("", "viewer", time.Now().Add(time.Millisecond))
+	svc.timersMu.Lock()
+	time.Sleep(20 * time.Millisecond)
+	replacement := time.AfterFunc(time.Hour, func() {})
+	defer replacement.Stop()
+	svc.timers[key] = replacement
+	svc.timersMu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	svc.timersMu.Lock()
+	defer svc.timersMu.Unlock()
+	if svc.timers[key] != replacement {
+		t.Error("the fired timer removed its replacement")
+	}
+}
+
+type mockTenantGrantsRepository struct {
+	mockTenantPrivilegeRepository
+	scoped map[string]map[string][]ScopedGrant
+	timed  map[string]map[string][]PrivilegeGrant
+}
+
+func (m *mockTenantGrantsRepository) FetchTenantScopedPrivilegesByRoleID(ctx context.Context, tenantID string, roleID string) ([]ScopedGrant, error) {
+	return m.scoped[tenantID][roleID], nil
+}
+
+func (m *mockTenantGrantsRepository) FetchTenantTimedPrivilegesByRoleID(ctx context.Context, tenantID string, roleID string) ([]PrivilegeGrant, error) {
+	return m.timed[tenantID][roleID], nil
+}
+
+func TestRBACService_Tenants_ExtraGrants(t *testing.T) {
+	repo := &mockTenantGrantsRepository{
+		mockTenantPrivilegeRepository: mockTenantPrivilegeRepository{tenants: map[string]map[string]map[string]bool{
+			"acme":   {"auditor": {"edit:projects": true}},
+			"globex": {"auditor": {"edit:projects": true}},
+		}},
+		scoped: map[string]map[string][]ScopedGrant{
+			"acme": {"auditor": {{Privilege: "!edit:projects", ResourceType: "project", ResourceID: "42"}}},
+		},
+		timed: map[string]map[string][]PrivilegeGrant{
+			"acme": {"auditor": {{Privilege: "read:ledger", ValidUntil: time.Now().Add(50 * time.Millisecond)}}},
+		},
+	}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+	ctx := context.Background()
+
+	tests := []struct {
+		tenantID  string
+		privilege string
+		want      bool
+	}{
+		{tenantID: "acme", privilege: "read:ledger", want: true},
+		{tenantID: "acme", privilege: "edit:projects", want: false},
+		{tenantID: "globex", privilege: "read:ledger", want: false},
+		{tenantID: "globex", privilege: "edit:projects", want: true},
+	}
+	for _, tt := range tests {
+		got, err := svc.HasPrivilegeOn(InjectTenant(ctx, tt.tenantID), "auditor", tt.privilege, "project", "42")
+		if err != nil {
+			t.Fatalf("HasPrivilegeOn(%s, %s) error = %v", tt.tenantID, tt.privilege, err)
+		}
+		if got != tt.want {
+			t.Errorf("HasPrivilegeOn(%s, %s) got = %v, want %v", tt.tenantID, tt.privilege, got, tt.want)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ok := svc.tenants.Get("acme", "auditor"); ok {
+		t.Errorf("expired tenant entry should be dropped by its scheduled invalidation")
+	}
+	if ok, _ := svc.HasTenantPrivilege(ctx, "acme", "auditor", "read:ledger"); ok {
+		t.Errorf("HasTenantPrivilege() should be false once the grant ended")
+	}
+}
+
+// mockTenantTimedPrivilegeRepository keeps time-bounded grants outside tenants only
+type mockTenantTimedPrivilegeRepository struct {
+	mockTenantPrivilegeRepository
+}
+
+func (m *mockTenantTimedPrivilegeRepository) FetchTimedPrivilegesByRoleID(ctx context.Context, roleID string) ([]PrivilegeGrant, error) {
+	return nil, nil
+}
+
+func TestRBACService_Tenants_ExtraGrantsNotSupported(t *testing.T) {
+	repo := &mockTenantTimedPrivilegeRepository{
+		mockTenantPrivilegeRepository: mockTenantPrivilegeRepository{tenants: map[string]map[string]map[string]bool{
+			"acme": {"auditor": {"read:users": true}},
+		}},
+	}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+
+	_, err := svc.HasTenantPrivilege(context.Background(), "acme", "auditor", "read:users")
+	if !errors.Is(err, ErrTenantsNotSupported) {
+		t.Errorf("HasTenantPrivilege() error = %v, want %v", err, ErrTenantsNotSupported)
+	}
+	if ok, err := svc.HasPrivilege(context.Background(), "auditor", "read:users"); err != nil || ok {
+		t.Errorf("HasPrivilege() outside tenants got = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestRBACService_HasPrivilege_GrantExpires(t *testing.T) {
+	repo := &mockTimedPrivilegeRepository{grants: map[string][]PrivilegeGrant{
+		"auditor": {{Privilege: "read:ledger", ValidUntil: time.Now().Add(50 * time.Millisecond)}},
+	}}
+	svc := NewRBACService(repo, 0, nil).(*rbacService)
+	ctx := context.Background()
+
+	if ok, _ := svc.HasPrivilege(ctx, "auditor", "read:ledger"); !ok {
+		t.Fatalf("HasPrivilege() should be true before the grant ends")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if svc.localCache().Len() != 0 {
+		t.Errorf("expired entry should be dropped by its scheduled invalidation")
+	}
+	if ok, _ := svc.HasPrivilege(ctx, "auditor", "read:ledger"); ok {
+		t.Errorf("HasPrivilege() should be false once the grant ended")
+	}
+}
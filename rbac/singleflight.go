@@ -0,0 +1,79 @@
+package rbac
+
+import (
+	"context"
+	"sync"
+)
+
+// loadGroup coalesces concurrent loads of the same role, so a burst of cache misses
+// results in a single repository fetch whose result (or error) all callers share
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+// loadCall is a load in flight
+type loadCall struct {
+	done    chan struct{}
+	result  PrivilegeSet
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do runs load once per key at a time. The load runs on a context detached from the
+// callers' cancellation (values such as the tenant are kept), so a caller giving up
+// only fails itself; the load is cancelled once every waiting caller has given up.
+func (g *loadGroup) do(ctx context.Context, key string, load func(ctx context.Context) (PrivilegeSet, error)) (PrivilegeSet, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+
+	call, inFlight := g.calls[key]
+	if !inFlight {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &loadCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+
+		go func() {
+			call.result, call.err = load(loadCtx)
+
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return PrivilegeSet{}, ctx.Err()
+	}
+}
+
+// loadRolePrivilegesOnce loads a role through the load group, sharing the fetch
+// with any concurrent load of the same role in the same tenant
+func (s *rbacService) loadRolePrivilegesOnce(ctx context.Context, roleID string) (PrivilegeSet, error) {
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	return s.loads.do(ctx, tenantRoleKey(tenantID, roleID), func(ctx context.Context) (PrivilegeSet, error) {
+		return s.loadRolePrivileges(ctx, roleID)
+	})
+}
@@ -0,0 +1,109 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedPrivilegeRepository holds every fetch until release is closed
+type gatedPrivilegeRepository struct {
+	release chan struct{}
+	calls   atomic.Int32
+	err     error
+}
+
+func (g *gatedPrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	g.calls.Add(1)
+	select {
+	case <-g.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+	return map[string]bool{"read:users": true}, nil
+}
+
+func TestRBACService_GetRolePrivileges_Coalesces(t *testing.T) {
+	errFetch := errors.New("database unavailable")
+
+	tests := []struct {
+		name    string
+		repoErr error
+	}{
+		{name: "shared result"},
+		{name: "shared error", repoErr: errFetch},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &gatedPrivilegeRepository{release: make(chan struct{}), err: tt.repoErr}
+			svc := NewRBACService(repo, 0, nil)
+
+			const callers = 50
+			errs := make(chan error, callers)
+			var wg sync.WaitGroup
+			for c := 0; c < callers; c++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := svc.GetRolePrivileges(context.Background(), "viewer")
+					errs <- err
+				}()
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			close(repo.release)
+			wg.Wait()
+			close(errs)
+
+			if got := repo.calls.Load(); got != 1 {
+				t.Errorf("repository calls = %d, want 1", got)
+			}
+			for err := range errs {
+				if !errors.Is(err, tt.repoErr) {
+					t.Errorf("GetRolePrivileges() error = %v, want %v", err, tt.repoErr)
+				}
+			}
+		})
+	}
+}
+
+func TestRBACService_GetRolePrivileges_CancelledWaiter(t *testing.T) {
+	repo := &gatedPrivilegeRepository{release: make(chan struct{})}
+	svc := NewRBACService(repo, 0, nil)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error, 1)
+	go func() {
+		_, err := svc.GetRolePrivileges(cancelledCtx, "viewer")
+		cancelledErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	waiterErr := make(chan error, 1)
+	go func() {
+		_, err := svc.GetRolePrivileges(context.Background(), "viewer")
+		waiterErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-cancelledErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller error = %v, want %v", err, context.Canceled)
+	}
+
+	close(repo.release)
+	if err := <-waiterErr; err != nil {
+		t.Errorf("other waiter should not fail, got %v", err)
+	}
+	if got := repo.calls.Load(); got != 1 {
+		t.Errorf("repository calls = %d, want 1", got)
+	}
+}
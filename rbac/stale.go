@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"context"
+	"time"
+)
+
+// CacheEntryInfo describes the cached entry of a role
+type CacheEntryInfo struct {
+	RoleID   string
+	TenantID string
+	LoadedAt time.Time
+	Age      time.Duration
+	// ExpiresAt is when the entry stops being served; zero if never
+	ExpiresAt time.Time
+	// Stale reports whether the entry is past the stale-after age of
+	// WithStaleWhileRevalidate and due for a reload
+	Stale bool
+}
+
+// CacheEntry describes the cached entry of a role, for the tenant in ctx. It reports
+// false when the role is not cached; it never loads the role.
+func (s *rbacService) CacheEntry(ctx context.Context, roleID string) (CacheEntryInfo, bool) {
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	entry, exist := s.cachedEntry(ctx, tenantID, roleID)
+	if !exist {
+		return CacheEntryInfo{}, false
+	}
+
+	return CacheEntryInfo{
+		RoleID:    roleID,
+		TenantID:  tenantID,
+		LoadedAt:  entry.LoadedAt,
+		Age:       time.Since(entry.LoadedAt),
+		ExpiresAt: entry.ExpiresAt,
+		Stale:     s.isStale(entry.LoadedAt),
+	}, true
+}
+
+// entryTTL is the longest a cache entry may be served: the cache TTL, bounded by
+// the maximum stale age
+func (s *rbacService) entryTTL() time.Duration {
+	if s.staleAfter <= 0 || s.maxStaleAge <= 0 {
+		return s.cacheTTL
+	}
+	if s.cacheTTL > 0 && s.cacheTTL < s.maxStaleAge {
+		return s.cacheTTL
+	}
+	return s.maxStaleAge
+}
+
+// isStale reports whether an entry loaded at loadedAt is due for a background reload
+func (s *rbacService) isStale(loadedAt time.Time) bool {
+	return s.staleAfter > 0 && time.Since(loadedAt) >= s.staleAfter
+}
+
+// revalidate reloads a role in the background, at most once at a time per role and
+// not after Close. A failed reload leaves the cached entry in place.
+func (s *rbacService) revalidate(ctx context.Context, tenantID, roleID string) {
+	key := tenantRoleKey(tenantID, roleID)
+	if _, running := s.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	// The reload outlives the request that noticed the stale entry
+	ctx = context.WithoutCancel(ctx)
+	started := s.goBackground(func() {
+		defer s.revalidating.Delete(key)
+
+		s.stats.revalidations.Add(1)
+		if _, err := s.loadRolePrivilegesOnce(ctx, roleID); err != nil {
+			s.logger.Errorf("Error revalidating privileges for role %s: %v", roleID, err)
+		}
+	})
+	if !started {
+		s.revalidating.Delete(key)
+	}
+}
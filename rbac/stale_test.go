@@ -0,0 +1,216 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// switchablePrivilegeRepository is safe for use by background reloads
+type switchablePrivilegeRepository struct {
+	mu         sync.Mutex
+	privileges map[string]bool
+	err        error
+}
+
+func (r *switchablePrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	result := make(map[string]bool)
+	for code, granted := range r.privileges {
+		result[code] = granted
+	}
+	return result, nil
+}
+
+func (r *switchablePrivilegeRepository) set(privileges map[string]bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.privileges = privileges
+	r.err = err
+}
+
+// waitForRevalidation waits until no background reload is running
+func waitForRevalidation(t *testing.T, svc *rbacService) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		running := false
+		svc.revalidating.Range(func(key, value any) bool {
+			running = true
+			return false
+		})
+		if !running {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("background reload did not finish")
+}
+
+func TestRBACService_StaleWhileRevalidate(t *testing.T) {
+	repoErr := errors.New("db down")
+
+	tests := []struct {
+		name      string
+		reloadErr error
+		want      map[string]bool
+	}{
+		{name: "reload succeeds", want: map[string]bool{"write:users": true}},
+		{name: "reload fails", reloadErr: repoErr, want: map[string]bool{"read:users": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &switchablePrivilegeRepository{privileges: map[string]bool{"read:users": true}}
+			svc := NewRBACService(repo, 0, nil, WithStaleWhileRevalidate(10*time.Millisecond, time.Minute)).(*rbacService)
+			ctx := context.Background()
+
+			if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			repo.set(map[string]bool{"write:users": true}, tt.reloadErr)
+			time.Sleep(15 * time.Millisecond)
+
+			// The stale entry is served while it is reloaded
+			got, err := svc.GetRolePrivileges(ctx, "viewer")
+			if err != nil || !got["read:users"] {
+				t.Fatalf("expected stale privileges, got %v, %v", got, err)
+			}
+			waitForRevalidation(t, svc)
+
+			got, err = svc.GetRolePrivileges(ctx, "viewer")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !PrivilegeSetFromMap(got).Equal(PrivilegeSetFromMap(tt.want)) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+
+			stats := svc.Stats()
+			if stats.StaleHits == 0 || stats.Revalidations == 0 {
+				t.Errorf("expected stale hits and revalidations, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestRBACService_StaleWhileRevalidate_MaxAge(t *testing.T) {
+	repo := &switchablePrivilegeRepository{privileges: map[string]bool{"read:users": true}}
+	svc := NewRBACService(repo, 0, nil, WithStaleWhileRevalidate(time.Millisecond, 20*time.Millisecond)).(*rbacService)
+	ctx := context.Background()
+
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repoErr := errors.New("db down")
+	repo.set(nil, repoErr)
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("expected stale privileges before the max age, got %v", err)
+	}
+	waitForRevalidation(t, svc)
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); !errors.Is(err, repoErr) {
+		t.Errorf("expected the repository error past the max age, got %v", err)
+	}
+}
+
+func TestRBACService_StaleWhileRevalidate_Handle(t *testing.T) {
+	repo := &switchablePrivilegeRepository{privileges: map[string]bool{"read:users": true}}
+	svc := NewRBACService(repo, 0, nil, WithStaleWhileRevalidate(time.Millisecond, time.Minute)).(*rbacService)
+	ctx := context.Background()
+	readUsers := ResolvePrivilege("read:users")
+
+	if _, err := svc.HasPrivilegeHandle(ctx, "viewer", readUsers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repo.set(map[string]bool{"write:users": true}, nil)
+	time.Sleep(5 * time.Millisecond)
+
+	// The stale bitset answers while the role is reloaded
+	if has, err := svc.HasPrivilegeHandle(ctx, "viewer", readUsers); err != nil || !has {
+		t.Fatalf("expected the stale privilege, got %v, %v", has, err)
+	}
+	waitForRevalidation(t, svc)
+
+	if has, err := svc.HasPrivilegeHandle(ctx, "viewer", readUsers); err != nil || has {
+		t.Errorf("expected the reloaded privileges, got %v, %v", has, err)
+	}
+	if stats := svc.Stats(); stats.StaleHits == 0 || stats.Revalidations == 0 {
+		t.Errorf("expected a stale hit and a revalidation, got %+v", stats)
+	}
+}
+
+func TestRBACService_StaleWhileRevalidate_CloseWaits(t *testing.T) {
+	repo := &switchablePrivilegeRepository{privileges: map[string]bool{"read:users": true}}
+	svc := NewRBACService(repo, 0, nil, WithStaleWhileRevalidate(time.Millisecond, time.Minute)).(*rbacService)
+	ctx := context.Background()
+
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Holding the repository's lock keeps the background reload running
+	repo.mu.Lock()
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	closed := make(chan struct{})
+	go func() {
+		svc.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close() returned while a reload was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	repo.mu.Unlock()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() did not return after the reload finished")
+	}
+	if got := svc.Stats().Revalidations; got != 1 {
+		t.Errorf("expected 1 revalidation, got %d", got)
+	}
+}
+
+func TestRBACService_CacheEntry(t *testing.T) {
+	svc, _ := newTestService(map[string]map[string]bool{
+		"viewer": {"read:users": true},
+	}, WithStaleWhileRevalidate(5*time.Millisecond, time.Minute))
+	ctx := context.Background()
+
+	if _, ok := svc.CacheEntry(ctx, "viewer"); ok {
+		t.Fatal("expected no entry before the first lookup")
+	}
+	if _, err := svc.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, ok := svc.CacheEntry(ctx, "viewer")
+	if !ok || info.Stale || info.LoadedAt.IsZero() || info.ExpiresAt.IsZero() {
+		t.Fatalf("expected a fresh entry with an expiry, got %+v, %v", info, ok)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	info, ok = svc.CacheEntry(ctx, "viewer")
+	if !ok || !info.Stale || info.Age < 5*time.Millisecond {
+		t.Errorf("expected a stale entry, got %+v, %v", info, ok)
+	}
+}
@@ -0,0 +1,73 @@
+package rbac
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the service's cache metrics. Counters are cumulative
+// since the service was created.
+type Stats struct {
+	Hits       uint64 // lookups answered from the cache
+	Misses     uint64 // lookups that had to load from the repository
+	Loads      uint64 // repository loads, including refreshes
+	LoadErrors uint64 // failed loads, not counting roles not found
+
+	// NotFound counts loads for which the repository reported the role as not found
+	NotFound uint64
+	// NegativeHits counts lookups answered from the negative cache
+	NegativeHits uint64
+	// Evictions counts roles the caches dropped on their own, for capacity or expiry
+	Evictions uint64
+	// StaleHits counts lookups answered with an entry past its stale-after age
+	StaleHits uint64
+	// Revalidations counts background reloads of stale entries
+	Revalidations uint64
+
+	Entries         int // cached roles outside any tenant
+	NegativeEntries int // roles remembered as not found, across tenants
+
+	// OldestEntryAge is the age of the oldest role cached outside any tenant
+	OldestEntryAge time.Duration
+}
+
+// serviceStats holds the live counters behind Stats
+type serviceStats struct {
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	loads         atomic.Uint64
+	loadErrors    atomic.Uint64
+	notFound      atomic.Uint64
+	negativeHits  atomic.Uint64
+	evictions     atomic.Uint64
+	staleHits     atomic.Uint64
+	revalidations atomic.Uint64
+}
+
+// Stats returns a snapshot of the cache metrics
+func (s *rbacService) Stats() Stats {
+	stats := Stats{
+		Hits:            s.stats.hits.Load(),
+		Misses:          s.stats.misses.Load(),
+		Loads:           s.stats.loads.Load(),
+		LoadErrors:      s.stats.loadErrors.Load(),
+		NotFound:        s.stats.notFound.Load(),
+		NegativeHits:    s.stats.negativeHits.Load(),
+		Evictions:       s.stats.evictions.Load(),
+		StaleHits:       s.stats.staleHits.Load(),
+		Revalidations:   s.stats.revalidations.Load(),
+		Entries:         s.cache.Stats().Entries,
+		NegativeEntries: s.negative.len(),
+	}
+	if local, ok := s.cache.(*RolePrivilegesCache); ok {
+		if oldest := local.oldestLoadedAt(); !oldest.IsZero() {
+			stats.OldestEntryAge = time.Since(oldest)
+		}
+	}
+	return stats
+}
+
+func isRoleNotFound(err error) bool {
+	return errors.Is(err, ErrRoleNotFound)
+}
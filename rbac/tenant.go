@@ -0,0 +1,91 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTenantsNotSupported is returned for tenant scoped lookups when the repository
+// does not implement TenantPrivilegeRepository
+var ErrTenantsNotSupported = errors.New("rbac: repository does not support tenants")
+
+// cacheFor returns the role cache of a tenant, creating it on first use; the global
+// cache for "". Only writes should use it, so that lookups of unknown tenants do not
+// leave empty caches behind.
+func (s *rbacService) cacheFor(tenantID string) PrivilegeCache {
+	if tenantID == "" {
+		return s.cache
+	}
+	return s.tenants.roleCache(tenantID)
+}
+
+// existingCacheFor returns the role cache of a tenant if it has one; the global
+// cache for ""
+func (s *rbacService) existingCacheFor(tenantID string) (PrivilegeCache, bool) {
+	if tenantID == "" {
+		return s.cache, true
+	}
+	if tenant, exist := s.tenants.existing(tenantID); exist {
+		return tenant, true
+	}
+	return nil, false
+}
+
+// localCacheFor returns the role cache of a tenant if it has one and it is in-process
+func (s *rbacService) localCacheFor(tenantID string) (*RolePrivilegesCache, bool) {
+	cache, ok := s.existingCacheFor(tenantID)
+	if !ok {
+		return nil, false
+	}
+	local, ok := cache.(*RolePrivilegesCache)
+	return local, ok
+}
+
+// forgetIfEmpty drops the cache of a tenant left without roles
+func (s *rbacService) forgetIfEmpty(tenantID string) {
+	if tenantID != "" {
+		s.tenants.dropIfEmpty(tenantID)
+	}
+}
+
+// GetTenantRolePrivileges returns the privileges for a given role ID within a tenant
+func (s *rbacService) GetTenantRolePrivileges(ctx context.Context, tenantID string, roleID string) (map[string]bool, error) {
+	return s.GetRolePrivileges(InjectTenant(ctx, tenantID), roleID)
+}
+
+// HasTenantPrivilege checks if a given role has a specific privilege within a tenant
+func (s *rbacService) HasTenantPrivilege(ctx context.Context, tenantID string, roleID string, privilege string) (bool, error) {
+	return s.HasPrivilege(InjectTenant(ctx, tenantID), roleID, privilege)
+}
+
+// InvalidateTenantRole removes a role of a tenant from the cache, together with every
+// cached role of that tenant inheriting from it. Unlike DeleteRolePrivileges it never
+// writes to a PrivilegeWriter.
+func (s *rbacService) InvalidateTenantRole(ctx context.Context, tenantID string, roleID string) error {
+	s.invalidateRoleLocal(ctx, tenantID, roleID)
+	return s.publishInvalidation(ctx, tenantID, roleID)
+}
+
+// InvalidateTenant removes every cached role of a tenant. Other tenants are untouched;
+// the empty tenant ID refers to the roles cached outside any tenant.
+func (s *rbacService) InvalidateTenant(ctx context.Context, tenantID string) error {
+	if err := s.invalidateTenantLocal(ctx, tenantID); err != nil {
+		return err
+	}
+	return s.publishInvalidation(ctx, tenantID, "")
+}
+
+// invalidateTenantLocal drops every role of a tenant from the local caches
+func (s *rbacService) invalidateTenantLocal(ctx context.Context, tenantID string) error {
+	if tenantID == "" {
+		if err := s.cache.Clear(ctx); err != nil {
+			return err
+		}
+	} else {
+		s.tenants.ClearTenant(tenantID)
+	}
+	s.hierarchy.untrackTenant(tenantID)
+	s.negative.removeTenant(tenantID)
+	s.loaded.removeTenant(tenantID)
+	return nil
+}
@@ -0,0 +1,135 @@
+package rbac
+
+import (
+	"sync"
+	"time"
+)
+
+// TenantPrivilegesCache keeps one RolePrivilegesCache per tenant, so the same role ID
+// in two tenants never shares an entry
+type TenantPrivilegesCache struct {
+	mu                  sync.RWMutex
+	tenants             map[string]*RolePrivilegesCache
+	maxEntriesPerTenant int
+
+	ttl      time.Duration
+	onEvict  func(tenantID, roleID string, reason EvictionReason)
+	compiled bool
+}
+
+// NewTenantPrivilegesCache creates a new TenantPrivilegesCache. When maxEntriesPerTenant
+// is greater than 0, each tenant holds at most that many roles and evicts the least
+// recently used one beyond it.
+func NewTenantPrivilegesCache(maxEntriesPerTenant int) *TenantPrivilegesCache {
+	return &TenantPrivilegesCache{
+		tenants:             make(map[string]*RolePrivilegesCache),
+		maxEntriesPerTenant: maxEntriesPerTenant,
+	}
+}
+
+// Get retrieves the privileges for a given role ID within a tenant
+func (c *TenantPrivilegesCache) Get(tenantID, roleID string) (map[string]bool, bool) {
+	tenant, exist := c.existing(tenantID)
+	if !exist {
+		return nil, false
+	}
+
+	return tenant.Get(roleID)
+}
+
+// Set sets the privileges for a given role ID within a tenant
+func (c *TenantPrivilegesCache) Set(tenantID, roleID string, privileges map[string]bool) {
+	c.roleCache(tenantID).Set(roleID, privileges)
+}
+
+// Delete deletes the privileges for a given role ID within a tenant. A tenant left
+// without roles is forgotten.
+func (c *TenantPrivilegesCache) Delete(tenantID, roleID string) {
+	if tenant, exist := c.existing(tenantID); exist {
+		tenant.Delete(roleID)
+		c.dropIfEmpty(tenantID)
+	}
+}
+
+// ClearTenant removes every cached role of a tenant
+func (c *TenantPrivilegesCache) ClearTenant(tenantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.tenants, tenantID)
+}
+
+// ClearCache clears the cache for all tenants
+func (c *TenantPrivilegesCache) ClearCache() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tenants = make(map[string]*RolePrivilegesCache)
+}
+
+// GetAllTenants returns all tenant IDs with cached roles
+func (c *TenantPrivilegesCache) GetAllTenants() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tenantIDs := make([]string, 0, len(c.tenants))
+	for tenantID := range c.tenants {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	return tenantIDs
+}
+
+// GetAllKeys returns all role IDs cached for a tenant
+func (c *TenantPrivilegesCache) GetAllKeys(tenantID string) []string {
+	tenant, exist := c.existing(tenantID)
+	if !exist {
+		return []string{}
+	}
+
+	return tenant.GetAllKeys()
+}
+
+// existing returns the cache of a tenant without creating it
+func (c *TenantPrivilegesCache) existing(tenantID string) (*RolePrivilegesCache, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tenant, exist := c.tenants[tenantID]
+	return tenant, exist
+}
+
+// dropIfEmpty forgets a tenant whose cache holds no roles, so tenants that were only
+// looked up, or whose roles all left, do not pile up. A write racing the removal
+// lands in the forgotten cache, which only costs a reload.
+func (c *TenantPrivilegesCache) dropIfEmpty(tenantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tenant, exist := c.tenants[tenantID]; exist && tenant.Len() == 0 {
+		delete(c.tenants, tenantID)
+	}
+}
+
+// roleCache returns the cache of a tenant, creating it on first use. Only writes
+// use it; reads go through existing.
+func (c *TenantPrivilegesCache) roleCache(tenantID string) *RolePrivilegesCache {
+	if tenant, exist := c.existing(tenantID); exist {
+		return tenant
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tenant, exist := c.tenants[tenantID]; exist {
+		return tenant
+	}
+	config := cacheConfig{maxEntries: c.maxEntriesPerTenant, ttl: c.ttl, compiled: c.compiled}
+	if c.onEvict != nil {
+		config.onEvict = func(roleID string, reason EvictionReason) {
+			c.onEvict(tenantID, roleID, reason)
+		}
+	}
+	tenant := newConfiguredRolePrivilegesCache(config)
+	c.tenants[tenantID] = tenant
+	return tenant
+}
@@ -0,0 +1,60 @@
+package rbac
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTenantPrivilegesCache_Isolation(t *testing.T) {
+	c := NewTenantPrivilegesCache(0)
+	c.Set("acme", "admin", map[string]bool{"delete:users": true})
+	c.Set("globex", "admin", map[string]bool{"read:users": true})
+
+	tests := []struct {
+		name     string
+		tenantID string
+		want     map[string]bool
+		wantOk   bool
+	}{
+		{name: "first tenant", tenantID: "acme", want: map[string]bool{"delete:users": true}, wantOk: true},
+		{name: "second tenant", tenantID: "globex", want: map[string]bool{"read:users": true}, wantOk: true},
+		{name: "unknown tenant", tenantID: "initech", want: nil, wantOk: false},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := c.Get(tt.tenantID, "admin")
+			if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TenantPrivilegesCache.Get() got = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	c.ClearTenant("acme")
+	if _, ok := c.Get("acme", "admin"); ok {
+		t.Errorf("ClearTenant() should remove the tenant's roles")
+	}
+	if _, ok := c.Get("globex", "admin"); !ok {
+		t.Errorf("ClearTenant() should not touch other tenants")
+	}
+}
+
+func TestTenantPrivilegesCache_MaxEntriesPerTenant(t *testing.T) {
+	c := NewTenantPrivilegesCache(2)
+	c.Set("acme", "viewer", map[string]bool{})
+	c.Set("acme", "editor", map[string]bool{})
+	c.Get("acme", "viewer") // editor is now least recently used
+	c.Set("acme", "admin", map[string]bool{})
+	c.Set("globex", "viewer", map[string]bool{})
+
+	got := c.GetAllKeys("acme")
+	sort.Strings(got)
+	if want := []string{"admin", "viewer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAllKeys(acme) got = %v, want %v", got, want)
+	}
+	if got := c.GetAllKeys("globex"); len(got) != 1 {
+		t.Errorf("limit should apply per tenant, globex has %v", got)
+	}
+}
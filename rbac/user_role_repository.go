@@ -0,0 +1,16 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoUserRoleRepository is returned by user based lookups when the service
+// was built without a UserRoleRepository
+var ErrNoUserRoleRepository = errors.New("rbac: no user role repository configured")
+
+// UserRoleRepository resolves the roles assigned to a user.
+// A user may hold any number of roles; their privileges are combined.
+type UserRoleRepository interface {
+	FetchRoleIDsByUserID(ctx context.Context, userID string) ([]string, error)
+}
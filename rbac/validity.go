@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"context"
+	"time"
+)
+
+// activeGrants returns the privileges of the grants valid at now, and the earliest
+// moment after now at which that set changes (zero if it never does)
+func activeGrants(grants []PrivilegeGrant, now time.Time) ([]string, time.Time) {
+	var (
+		active     []string
+		nextChange time.Time
+	)
+
+	for _, grant := range grants {
+		if grant.ActiveAt(now) {
+			active = append(active, grant.Privilege)
+		}
+		if grant.ValidFrom.After(now) {
+			nextChange = earliest(nextChange, grant.ValidFrom)
+		}
+		if grant.ValidUntil.After(now) {
+			nextChange = earliest(nextChange, grant.ValidUntil)
+		}
+	}
+
+	return active, nextChange
+}
+
+// earliest returns the earlier of two times, treating zero as "never"
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// scheduleExpiry arranges for a cached role to be dropped at expiresAt, when one of
+// its time-bounded grants starts or ends. Any previously scheduled drop is replaced.
+// Lookups never serve an expired entry either way; this only frees it eagerly,
+// lets dependents reload and, with OnRoleChanged handlers, reports the change.
+func (s *rbacService) scheduleExpiry(tenantID, roleID string, expiresAt time.Time) {
+	key := tenantRoleKey(tenantID, roleID)
+
+	s.timersMu.Lock()
+	defer s.timersMu.Unlock()
+
+	if timer, ok := s.timers[key]; ok {
+		timer.Stop()
+		delete(s.timers, key)
+	}
+	if expiresAt.IsZero() || s.isClosed() {
+		return
+	}
+
+	if s.timers == nil {
+		s.timers = make(map[string]*time.Timer)
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(expiresAt), func() {
+		// A reload may have replaced this timer after it fired but before it got
+		// the lock; the replacement must stay
+		s.timersMu.Lock()
+		if s.timers[key] == timer {
+			delete(s.timers, key)
+		}
+		s.timersMu.Unlock()
+
+		if s.dropExpiredEntry(context.Background(), tenantID, roleID) {
+			s.logger.Debugf("Privileges of role %s expired", roleID)
+			s.hierarchy.untrack(tenantID, roleID)
+			s.invalidateDependents(tenantID, roleID)
+			s.reloadExpired(tenantID, roleID)
+		}
+	})
+	s.timers[key] = timer
+}
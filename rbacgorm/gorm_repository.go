@@ -2,40 +2,326 @@ package rbacgorm
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/hatmahat/go-rbac/rbac"
 	"gorm.io/gorm"
 )
 
 type GormPrivilegeRepository struct {
-	db *gorm.DB
+	db           *gorm.DB
+	mapping      Mapping
+	queryTimeout time.Duration
+
+	// detectRolesTable keeps the default roles table only if the database has it.
+	// The first fetch finds out and clears it; until then rolesTableMu guards it
+	// and mapping.RolesTable.
+	rolesTableMu     sync.Mutex
+	detectRolesTable bool
 }
 
-func NewGormPrivilegeRepository(db *gorm.DB) *GormPrivilegeRepository {
-	return &GormPrivilegeRepository{db: db}
+var _ rbac.StreamingPrivilegeRepository = (*GormPrivilegeRepository)(nil)
+
+// Option configures a GormPrivilegeRepository
+type Option func(*GormPrivilegeRepository)
+
+// WithRolesTable names the table holding one row per role, keyed by an `id` column.
+// When set, a role without privilege rows is looked up there and reported as
+// rbac.ErrRoleNotFound if missing; otherwise such a role yields an empty map. An
+// empty table name means there is no roles table.
+func WithRolesTable(table string) Option {
+	return func(g *GormPrivilegeRepository) {
+		g.mapping.RolesTable = table
+		g.detectRolesTable = false
+	}
 }
 
-func (g *GormPrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
-	query := `
-		SELECT p.code
-		FROM privileges p
-		JOIN role_privileges rp ON p.id = rp.privilege_id
-		WHERE rp.role_id = ?
-	`
-
-	rows, err := g.db.Raw(query, roleID).Rows()
-	if err != nil {
+// WithQueryTimeout bounds every query, and every write transaction, by timeout on
+// top of the deadline of the caller's context. FetchAll runs a single query, so the
+// timeout covers its whole pass. 0, the default, sets no timeout.
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(g *GormPrivilegeRepository) {
+		g.queryTimeout = timeout
+	}
+}
+
+// NewGormPrivilegeRepository creates a repository reading the schema created by
+// Migrate (see DefaultMapping). Its roles table is used only if db has one, since
+// schemas made by hand may lack it; WithRolesTable("") turns it off. The first
+// fetch looks the table up; if that fails, the fetch returns the error and the next
+// one tries again.
+//
+// Table names given by options are written into queries, so one that is not a plain
+// identifier panics with ErrInvalidMapping. NewGormPrivilegeRepositoryWithMapping
+// returns that error instead, and also checks the names against db.
+func NewGormPrivilegeRepository(db *gorm.DB, opts ...Option) *GormPrivilegeRepository {
+	g := &GormPrivilegeRepository{db: db, mapping: DefaultMapping(), detectRolesTable: true}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if err := g.mapping.Validate(); err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// NewGormPrivilegeRepositoryWithMapping creates a repository reading the tables and
+// columns named by mapping instead of the default schema. It fails with
+// ErrInvalidMapping if a name is not a plain identifier or cannot be read from db.
+func NewGormPrivilegeRepositoryWithMapping(db *gorm.DB, mapping Mapping, opts ...Option) (*GormPrivilegeRepository, error) {
+	g := &GormPrivilegeRepository{db: db, mapping: mapping.withDefaults()}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if err := g.mapping.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := g.withTimeout(context.Background())
+	defer cancel()
+	if err := g.mapping.check(db.WithContext(ctx)); err != nil {
 		return nil, err
 	}
+	return g, nil
+}
+
+// withTimeout bounds ctx by the query timeout, if one is set
+func (g *GormPrivilegeRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, g.queryTimeout)
+}
+
+// detectRoles drops the default roles table from the mapping if the database does
+// not have it, unless that is known already. Fetches call it before reading the
+// mapping.
+func (g *GormPrivilegeRepository) detectRoles(ctx context.Context) error {
+	g.rolesTableMu.Lock()
+	defer g.rolesTableMu.Unlock()
+	if !g.detectRolesTable {
+		return nil
+	}
+
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+	tables, err := g.db.WithContext(ctx).Migrator().GetTables()
+	if err != nil {
+		return fmt.Errorf("rbacgorm: looking up the %s table: %w", g.mapping.RolesTable, err)
+	}
+	if !slices.ContainsFunc(tables, func(table string) bool { return strings.EqualFold(table, g.mapping.RolesTable) }) {
+		g.mapping.RolesTable = ""
+	}
+	g.detectRolesTable = false
+	return nil
+}
+
+// grantsQuery selects the privilege codes granted to the roles matching
+// roleCondition, e.g. "= ?", or to every role if it is empty. withRole selects the
+// role ID first.
+func (g *GormPrivilegeRepository) grantsQuery(withRole bool, roleCondition string) string {
+	m := g.mapping
+	columns := "p." + m.PrivilegeCodeColumn
+	if withRole {
+		columns = "rp." + m.RolePrivilegeRoleColumn + ", " + columns
+	}
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s p
+		JOIN %s rp ON p.%s = rp.%s
+	`, columns, m.table(m.PrivilegesTable), m.table(m.RolePrivilegesTable),
+		m.PrivilegeKeyColumn, m.RolePrivilegePrivilegeColumn)
+
+	var conditions []string
+	if roleCondition != "" {
+		conditions = append(conditions, "rp."+m.RolePrivilegeRoleColumn+" "+roleCondition)
+	}
+	if m.PrivilegeSoftDeleteColumn != "" {
+		conditions = append(conditions, "p."+m.PrivilegeSoftDeleteColumn+" IS NULL")
+	}
+	if m.RolesTable != "" && m.RoleSoftDeleteColumn != "" {
+		conditions = append(conditions, fmt.Sprintf("rp.%s IN (SELECT %s FROM %s WHERE %s IS NULL)",
+			m.RolePrivilegeRoleColumn, m.RoleIDColumn, m.table(m.RolesTable), m.RoleSoftDeleteColumn))
+	}
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	return query
+}
+
+// scanGrants runs a grants query within the query timeout and calls fn with every
+// row read. roleID is empty unless the query selects it.
+func (g *GormPrivilegeRepository) scanGrants(ctx context.Context, withRole bool, query string, args []any, fn func(roleID, code string) error) error {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := g.db.WithContext(ctx).Raw(query, args...).Rows()
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	result := make(map[string]bool)
 	for rows.Next() {
-		var code string
-		if err := rows.Scan(&code); err != nil {
-			return nil, err
+		var roleID, code string
+		dest := []any{&code}
+		if withRole {
+			dest = []any{&roleID, &code}
 		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if err := fn(roleID, code); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (g *GormPrivilegeRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	if err := g.detectRoles(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]bool)
+	err := g.scanGrants(ctx, false, g.grantsQuery(false, "= ?"), []any{roleID}, func(_, code string) error {
 		result[code] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 && g.mapping.RolesTable != "" {
+		exists, err := g.roleExists(ctx, roleID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, &rbac.RoleNotFoundError{RoleID: roleID}
+		}
 	}
 
 	return result, nil
 }
+
+// FetchPrivilegesByRoleIDs fetches the privileges of many roles in one query. With
+// a roles table, roles without privilege rows that are missing from it are left out
+// of the result, so the service reports them as not found.
+func (g *GormPrivilegeRepository) FetchPrivilegesByRoleIDs(ctx context.Context, roleIDs []string) (map[string]map[string]bool, error) {
+	result := make(map[string]map[string]bool, len(roleIDs))
+	if len(roleIDs) == 0 {
+		return result, nil
+	}
+	if err := g.detectRoles(ctx); err != nil {
+		return nil, err
+	}
+
+	err := g.scanGrants(ctx, true, g.grantsQuery(true, "IN ?"), []any{roleIDs}, func(roleID, code string) error {
+		if result[roleID] == nil {
+			result[roleID] = make(map[string]bool)
+		}
+		result[roleID][code] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, roleID := range roleIDs {
+		if _, ok := result[roleID]; !ok {
+			missing = append(missing, roleID)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	existing := missing
+	if g.mapping.RolesTable != "" {
+		existing, err = g.existingRoles(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, roleID := range existing {
+		result[roleID] = make(map[string]bool)
+	}
+
+	return result, nil
+}
+
+// FetchAll reads the privileges of every role holding any in one query ordered by
+// role ID, calling fn as soon as a role's rows have been read. Only one role is held
+// in memory at a time. fn runs while the query is still open, so it should not wait
+// on the same database. Roles without privileges are not reported.
+func (g *GormPrivilegeRepository) FetchAll(ctx context.Context, fn func(roleID string, privileges map[string]bool) error) error {
+	if err := g.detectRoles(ctx); err != nil {
+		return err
+	}
+
+	m := g.mapping
+	query := g.grantsQuery(true, "") + fmt.Sprintf(" ORDER BY rp.%s, p.%s", m.RolePrivilegeRoleColumn, m.PrivilegeCodeColumn)
+
+	var current string
+	var privileges map[string]bool
+	err := g.scanGrants(ctx, true, query, nil, func(roleID, code string) error {
+		if privileges != nil && roleID != current {
+			if err := fn(current, privileges); err != nil {
+				return err
+			}
+			privileges = nil
+		}
+		if privileges == nil {
+			current = roleID
+			privileges = make(map[string]bool)
+		}
+		privileges[code] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if privileges != nil {
+		return fn(current, privileges)
+	}
+	return nil
+}
+
+// roleExists reports whether the roles table has a row for roleID
+func (g *GormPrivilegeRepository) roleExists(ctx context.Context, roleID string) (bool, error) {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := g.roles(ctx).Where(g.mapping.RoleIDColumn+" = ?", roleID).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// existingRoles returns the roleIDs the roles table has rows for
+func (g *GormPrivilegeRepository) existingRoles(ctx context.Context, roleIDs []string) ([]string, error) {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	var existing []string
+	err := g.roles(ctx).Where(g.mapping.RoleIDColumn+" IN ?", roleIDs).Pluck(g.mapping.RoleIDColumn, &existing).Error
+	return existing, err
+}
+
+// roles starts a query on the live rows of the roles table
+func (g *GormPrivilegeRepository) roles(ctx context.Context) *gorm.DB {
+	m := g.mapping
+	query := g.db.WithContext(ctx).Table(m.table(m.RolesTable))
+	if m.RoleSoftDeleteColumn != "" {
+		query = query.Where(m.RoleSoftDeleteColumn + " IS NULL")
+	}
+	return query
+}
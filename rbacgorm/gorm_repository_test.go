@@ -0,0 +1,264 @@
+package rbacgorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hatmahat/go-rbac/rbac"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newEmptyDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func execAll(t *testing.T, db *gorm.DB, statements ...string) {
+	t.Helper()
+
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("exec %q: %v", stmt, err)
+		}
+	}
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := newEmptyDB(t)
+	execAll(t, db,
+		`CREATE TABLE roles (id TEXT PRIMARY KEY, name TEXT NOT NULL DEFAULT '', description TEXT NOT NULL DEFAULT '')`,
+		`CREATE TABLE privileges (id INTEGER PRIMARY KEY, code TEXT NOT NULL UNIQUE, description TEXT NOT NULL DEFAULT '')`,
+		`CREATE TABLE role_privileges (role_id TEXT NOT NULL, privilege_id INTEGER NOT NULL)`,
+		`INSERT INTO roles (id) VALUES ('admin'), ('empty')`,
+		`INSERT INTO privileges (id, code) VALUES (1, 'user:read'), (2, 'user:write')`,
+		`INSERT INTO role_privileges (role_id, privilege_id) VALUES ('admin', 1), ('admin', 2)`,
+	)
+	return db
+}
+
+func TestGormPrivilegeRepository_FetchPrivilegesByRoleID(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		roleID       string
+		wantLen      int
+		wantNotFound bool
+	}{
+		{name: "role with privileges", roleID: "admin", wantLen: 2},
+		{name: "unknown role without roles table", opts: []Option{WithRolesTable("")}, roleID: "ghost", wantLen: 0},
+		{name: "role without privileges", opts: []Option{WithRolesTable("roles")}, roleID: "empty", wantLen: 0},
+		{name: "unknown role", opts: []Option{WithRolesTable("roles")}, roleID: "ghost", wantNotFound: true},
+		{name: "unknown role in detected roles table", roleID: "ghost", wantNotFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewGormPrivilegeRepository(db, tt.opts...)
+			got, err := repo.FetchPrivilegesByRoleID(context.Background(), tt.roleID)
+			if tt.wantNotFound {
+				if !errors.Is(err, rbac.ErrRoleNotFound) {
+					t.Fatalf("expected ErrRoleNotFound, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != tt.wantLen {
+				t.Errorf("expected %d privileges, got %v", tt.wantLen, got)
+			}
+		})
+	}
+}
+
+func TestNewGormPrivilegeRepository_NoRolesTable(t *testing.T) {
+	db := newEmptyDB(t)
+	execAll(t, db,
+		`CREATE TABLE privileges (id INTEGER PRIMARY KEY, code TEXT NOT NULL UNIQUE)`,
+		`CREATE TABLE role_privileges (role_id TEXT NOT NULL, privilege_id INTEGER NOT NULL)`,
+	)
+
+	got, err := NewGormPrivilegeRepository(db).FetchPrivilegesByRoleID(context.Background(), "ghost")
+	if err != nil || len(got) != 0 {
+		t.Errorf("expected an empty map without a roles table, got %v, %v", got, err)
+	}
+}
+
+func TestNewGormPrivilegeRepository_RolesTableLookupFails(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewGormPrivilegeRepository(db)
+
+	// A failed lookup is returned rather than taken for a missing table
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := repo.FetchPrivilegesByRoleID(ctx, "ghost"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the lookup error, got %v", err)
+	}
+
+	// The next fetch looks again and finds the table
+	if _, err := repo.FetchPrivilegesByRoleID(context.Background(), "ghost"); !errors.Is(err, rbac.ErrRoleNotFound) {
+		t.Errorf("expected ErrRoleNotFound, got %v", err)
+	}
+}
+
+func TestGormPrivilegeRepository_FetchPrivilegesByRoleIDs(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]int // role ID -> number of privileges
+	}{
+		{
+			name: "without roles table",
+			opts: []Option{WithRolesTable("")},
+			want: map[string]int{"admin": 2, "empty": 0, "ghost": 0},
+		},
+		{
+			name: "with roles table",
+			opts: []Option{WithRolesTable("roles")},
+			want: map[string]int{"admin": 2, "empty": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewGormPrivilegeRepository(db, tt.opts...)
+			got, err := repo.FetchPrivilegesByRoleIDs(context.Background(), []string{"admin", "empty", "ghost"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected roles %v, got %v", tt.want, got)
+			}
+			for roleID, n := range tt.want {
+				privileges, ok := got[roleID]
+				if !ok || len(privileges) != n {
+					t.Errorf("role %s: expected %d privileges, got %v", roleID, n, privileges)
+				}
+			}
+		})
+	}
+}
+
+func TestGormPrivilegeRepository_FetchAll(t *testing.T) {
+	db := newTestDB(t)
+	execAll(t, db,
+		`INSERT INTO roles (id) VALUES ('viewer')`,
+		`INSERT INTO role_privileges (role_id, privilege_id) VALUES ('viewer', 1)`,
+	)
+	repo := NewGormPrivilegeRepository(db)
+	ctx := context.Background()
+
+	type streamed struct {
+		roleID     string
+		privileges map[string]bool
+	}
+	var got []streamed
+	err := repo.FetchAll(ctx, func(roleID string, privileges map[string]bool) error {
+		got = append(got, streamed{roleID, privileges})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Roles come in ID order, once each; roles without privileges are left out
+	want := []streamed{
+		{"admin", map[string]bool{"user:read": true, "user:write": true}},
+		{"viewer", map[string]bool{"user:read": true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = repo.FetchAll(ctx, func(roleID string, privileges map[string]bool) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("expected the pass to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestGormPrivilegeRepository_Context(t *testing.T) {
+	calls := []struct {
+		name string
+		call func(ctx context.Context, repo *GormPrivilegeRepository) error
+	}{
+		{"FetchPrivilegesByRoleID", func(ctx context.Context, repo *GormPrivilegeRepository) error {
+			_, err := repo.FetchPrivilegesByRoleID(ctx, "empty")
+			return err
+		}},
+		{"FetchPrivilegesByRoleIDs", func(ctx context.Context, repo *GormPrivilegeRepository) error {
+			_, err := repo.FetchPrivilegesByRoleIDs(ctx, []string{"admin", "empty"})
+			return err
+		}},
+		{"FetchAll", func(ctx context.Context, repo *GormPrivilegeRepository) error {
+			return repo.FetchAll(ctx, func(string, map[string]bool) error { return nil })
+		}},
+		{"ReplaceRolePrivileges", func(ctx context.Context, repo *GormPrivilegeRepository) error {
+			return repo.ReplaceRolePrivileges(ctx, "empty", []string{"user:read"})
+		}},
+		{"DeleteRolePrivileges", func(ctx context.Context, repo *GormPrivilegeRepository) error {
+			return repo.DeleteRolePrivileges(ctx, "admin")
+		}},
+	}
+
+	for _, tc := range calls {
+		t.Run(tc.name+" cancelled", func(t *testing.T) {
+			repo := NewGormPrivilegeRepository(newTestDB(t), WithRolesTable("roles"))
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			if err := tc.call(ctx, repo); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+		})
+
+		t.Run(tc.name+" timeout", func(t *testing.T) {
+			db := newTestDB(t)
+			var statements, bounded int
+			record := func(db *gorm.DB) {
+				statements++
+				if _, ok := db.Statement.Context.Deadline(); ok {
+					bounded++
+				}
+			}
+			callbacks := db.Callback()
+			callbacks.Query().Before("gorm:query").Register("test:deadline", record)
+			callbacks.Row().Before("gorm:row").Register("test:deadline", record)
+			callbacks.Raw().Before("gorm:raw").Register("test:deadline", record)
+
+			repo := NewGormPrivilegeRepository(db, WithRolesTable("roles"), WithQueryTimeout(time.Minute))
+			if err := tc.call(context.Background(), repo); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if statements == 0 || bounded != statements {
+				t.Errorf("expected every statement to have a deadline, got %d of %d", bounded, statements)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package rbacgorm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hatmahat/go-rbac/rbac"
+	"gorm.io/gorm"
+)
+
+// DefaultInvalidationTable is the outbox table unless WithInvalidationTable says otherwise
+const DefaultInvalidationTable = "rbac_invalidations"
+
+// DefaultPollInterval is how often subscribers read the outbox unless WithPollInterval
+// says otherwise
+const DefaultPollInterval = time.Second
+
+// invalidationRecord is a row of the outbox table
+type invalidationRecord struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement"`
+	TenantID  string    `gorm:"size:255;not null;default:''"`
+	RoleID    string    `gorm:"size:255;not null;default:''"`
+	Source    string    `gorm:"size:64;not null;default:''"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+// GormInvalidationBus is an rbac.InvalidationBus backed by an outbox table: Publish
+// inserts a row and every subscriber polls for rows newer than the last it saw.
+// Any database GORM supports works, SQLite included.
+//
+// Rows are read in ID order, so on databases where IDs may commit out of order
+// (concurrent transactions on a sequence) an event can be missed; the periodic
+// refresh of the service bounds the damage.
+type GormInvalidationBus struct {
+	db           *gorm.DB
+	table        string
+	pollInterval time.Duration
+	onError      func(error)
+}
+
+var _ rbac.InvalidationBus = (*GormInvalidationBus)(nil)
+
+// InvalidationBusOption configures a GormInvalidationBus
+type InvalidationBusOption func(*GormInvalidationBus)
+
+// WithInvalidationTable sets the name of the outbox table
+func WithInvalidationTable(table string) InvalidationBusOption {
+	return func(b *GormInvalidationBus) {
+		b.table = table
+	}
+}
+
+// WithPollInterval sets how often subscribers read the outbox
+func WithPollInterval(interval time.Duration) InvalidationBusOption {
+	return func(b *GormInvalidationBus) {
+		b.pollInterval = interval
+	}
+}
+
+// WithPollErrorHandler is called when reading the outbox fails; polling goes on
+func WithPollErrorHandler(fn func(error)) InvalidationBusOption {
+	return func(b *GormInvalidationBus) {
+		b.onError = fn
+	}
+}
+
+// NewGormInvalidationBus creates a GormInvalidationBus. Call CreateTable once to set
+// up the outbox table.
+func NewGormInvalidationBus(db *gorm.DB, opts ...InvalidationBusOption) *GormInvalidationBus {
+	b := &GormInvalidationBus{db: db, table: DefaultInvalidationTable, pollInterval: DefaultPollInterval}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// CreateTable creates the outbox table if it does not exist
+func (b *GormInvalidationBus) CreateTable(ctx context.Context) error {
+	return b.db.WithContext(ctx).Table(b.table).AutoMigrate(&invalidationRecord{})
+}
+
+// Publish inserts event into the outbox
+func (b *GormInvalidationBus) Publish(ctx context.Context, event rbac.InvalidationEvent) error {
+	record := invalidationRecord{
+		TenantID:  event.TenantID,
+		RoleID:    event.RoleID,
+		Source:    event.Source,
+		CreatedAt: time.Now(),
+	}
+	return b.db.WithContext(ctx).Table(b.table).Create(&record).Error
+}
+
+// Subscribe polls the outbox for events published from now on and passes them to
+// handler in order. unsubscribe stops polling and waits for a running poll.
+func (b *GormInvalidationBus) Subscribe(handler func(rbac.InvalidationEvent)) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var last uint64
+	err := b.db.WithContext(ctx).Table(b.table).Select("COALESCE(MAX(id), 0)").Scan(&last).Error
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(b.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			next, err := b.poll(ctx, last, handler)
+			if err != nil && ctx.Err() == nil && b.onError != nil {
+				b.onError(err)
+			}
+			last = next
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}, nil
+}
+
+// poll delivers the events after last and returns the ID of the last one delivered
+func (b *GormInvalidationBus) poll(ctx context.Context, last uint64, handler func(rbac.InvalidationEvent)) (uint64, error) {
+	var records []invalidationRecord
+	err := b.db.WithContext(ctx).Table(b.table).Where("id > ?", last).Order("id").Limit(1000).Find(&records).Error
+	if err != nil {
+		return last, err
+	}
+
+	for _, record := range records {
+		handler(rbac.InvalidationEvent{TenantID: record.TenantID, RoleID: record.RoleID, Source: record.Source})
+		last = record.ID
+	}
+	return last, nil
+}
+
+// Prune deletes the events published before before, returning how many were deleted.
+// Run it now and then so the outbox does not grow forever.
+func (b *GormInvalidationBus) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result := b.db.WithContext(ctx).Table(b.table).Where("created_at < ?", before).Delete(&invalidationRecord{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,130 @@
+package rbacgorm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatmahat/go-rbac/rbac"
+)
+
+func TestGormInvalidationBus_PublishSubscribe(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	bus := NewGormInvalidationBus(db, WithPollInterval(5*time.Millisecond))
+	if err := bus.CreateTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	// Events published before subscribing are not delivered
+	if err := bus.Publish(ctx, rbac.InvalidationEvent{RoleID: "old"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	var mu sync.Mutex
+	var got []rbac.InvalidationEvent
+	unsubscribe, err := bus.Subscribe(func(event rbac.InvalidationEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event)
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	want := []rbac.InvalidationEvent{
+		{TenantID: "acme", RoleID: "viewer", Source: "pod-a"},
+		{RoleID: "editor", Source: "pod-b"},
+	}
+	for _, event := range want {
+		if err := bus.Publish(ctx, event); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n >= len(want) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestGormInvalidationBus_Prune(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	bus := NewGormInvalidationBus(db)
+	if err := bus.CreateTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	for _, roleID := range []string{"viewer", "editor"} {
+		if err := bus.Publish(ctx, rbac.InvalidationEvent{RoleID: roleID}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	deleted, err := bus.Prune(ctx, time.Now().Add(time.Second))
+	if err != nil || deleted != 2 {
+		t.Errorf("expected 2 pruned events, got %d, %v", deleted, err)
+	}
+}
+
+func TestGormInvalidationBus_Services(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	bus := NewGormInvalidationBus(db, WithPollInterval(5*time.Millisecond))
+	if err := bus.CreateTable(ctx); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	repo := NewGormPrivilegeRepository(db)
+	first := rbac.NewRBACService(repo, 0, nil, rbac.WithInvalidationBus(bus))
+	second := rbac.NewRBACService(repo, 0, nil, rbac.WithInvalidationBus(bus))
+	defer first.Close()
+	defer second.Close()
+
+	if ok, err := second.HasPrivilege(ctx, "admin", "user:write"); err != nil || !ok {
+		t.Fatalf("expected privilege, got %v, %v", ok, err)
+	}
+
+	// The role loses a privilege in the database and one instance is told about it
+	if err := db.Exec(`DELETE FROM role_privileges WHERE role_id = 'admin' AND privilege_id = 2`).Error; err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	if err := first.DeleteRolePrivileges(ctx, "admin"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		ok, err := second.HasPrivilege(ctx, "admin", "user:write")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the other instance kept serving the old privileges")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
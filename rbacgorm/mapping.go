@@ -0,0 +1,188 @@
+package rbacgorm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidMapping is returned by NewGormPrivilegeRepositoryWithMapping for a
+// Mapping that is malformed or does not match the database
+var ErrInvalidMapping = errors.New("rbacgorm: invalid mapping")
+
+// Mapping names the tables and columns GormPrivilegeRepository and GormRoleManager
+// read and write. Empty fields take their value from DefaultMapping, so only the
+// names that differ need to be set; RolesTable and the name and description columns
+// are the exception, as not every schema has them. A legacy schema linking roles to
+// permission names could be mapped as
+//
+//	rbacgorm.Mapping{
+//		PrivilegesTable:              "permissions",
+//		PrivilegeKeyColumn:           "name",
+//		PrivilegeCodeColumn:          "name",
+//		RolePrivilegesTable:          "role_permission",
+//		RolePrivilegePrivilegeColumn: "permission_code",
+//	}
+type Mapping struct {
+	// Schema, if set, qualifies every table, e.g. "auth" for auth.privileges
+	Schema string
+
+	PrivilegesTable     string
+	PrivilegeKeyColumn  string // referenced by RolePrivilegePrivilegeColumn
+	PrivilegeCodeColumn string // the privilege code checked by the service
+
+	RolePrivilegesTable          string
+	RolePrivilegeRoleColumn      string
+	RolePrivilegePrivilegeColumn string
+
+	// RolesTable, if set, is where roles without privileges are looked up, as with
+	// WithRolesTable. WithRolesTable overrides it. Unlike the other fields it is
+	// not defaulted.
+	RolesTable   string
+	RoleIDColumn string
+
+	// RoleNameColumn, RoleDescriptionColumn and PrivilegeDescriptionColumn are only
+	// used by GormRoleManager, which leaves a name or description empty if its
+	// column is not set. Like RolesTable, they are not defaulted.
+	RoleNameColumn             string
+	RoleDescriptionColumn      string
+	PrivilegeDescriptionColumn string
+
+	// PrivilegeSoftDeleteColumn and RoleSoftDeleteColumn name nullable columns that
+	// mark deleted rows. Rows where they are set are ignored, as if they did not
+	// exist; deleted privileges cannot be granted either.
+	PrivilegeSoftDeleteColumn string
+	RoleSoftDeleteColumn      string
+}
+
+// DefaultMapping returns the tables and columns created by Migrate
+func DefaultMapping() Mapping {
+	return Mapping{
+		RolesTable:                   "roles",
+		PrivilegesTable:              "privileges",
+		PrivilegeKeyColumn:           "id",
+		PrivilegeCodeColumn:          "code",
+		RolePrivilegesTable:          "role_privileges",
+		RolePrivilegeRoleColumn:      "role_id",
+		RolePrivilegePrivilegeColumn: "privilege_id",
+		RoleIDColumn:                 "id",
+		RoleNameColumn:               "name",
+		RoleDescriptionColumn:        "description",
+		PrivilegeDescriptionColumn:   "description",
+	}
+}
+
+// identifier matches the table, column and schema names a Mapping may use. They
+// are written into queries unquoted, so anything else is rejected.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// withDefaults returns m with its empty fields, except RolesTable and the name and
+// description columns, taken from DefaultMapping
+func (m Mapping) withDefaults() Mapping {
+	defaults := DefaultMapping()
+	for _, field := range []struct{ value, fallback *string }{
+		{&m.PrivilegesTable, &defaults.PrivilegesTable},
+		{&m.PrivilegeKeyColumn, &defaults.PrivilegeKeyColumn},
+		{&m.PrivilegeCodeColumn, &defaults.PrivilegeCodeColumn},
+		{&m.RolePrivilegesTable, &defaults.RolePrivilegesTable},
+		{&m.RolePrivilegeRoleColumn, &defaults.RolePrivilegeRoleColumn},
+		{&m.RolePrivilegePrivilegeColumn, &defaults.RolePrivilegePrivilegeColumn},
+		{&m.RoleIDColumn, &defaults.RoleIDColumn},
+	} {
+		if *field.value == "" {
+			*field.value = *field.fallback
+		}
+	}
+	return m
+}
+
+// Validate checks that every name in m, after defaults are applied, is a plain
+// identifier. It does not look at the database.
+func (m Mapping) Validate() error {
+	m = m.withDefaults()
+	for _, field := range []struct {
+		name     string
+		value    string
+		optional bool
+	}{
+		{"Schema", m.Schema, true},
+		{"PrivilegesTable", m.PrivilegesTable, false},
+		{"PrivilegeKeyColumn", m.PrivilegeKeyColumn, false},
+		{"PrivilegeCodeColumn", m.PrivilegeCodeColumn, false},
+		{"RolePrivilegesTable", m.RolePrivilegesTable, false},
+		{"RolePrivilegeRoleColumn", m.RolePrivilegeRoleColumn, false},
+		{"RolePrivilegePrivilegeColumn", m.RolePrivilegePrivilegeColumn, false},
+		{"RolesTable", m.RolesTable, true},
+		{"RoleIDColumn", m.RoleIDColumn, false},
+		{"RoleNameColumn", m.RoleNameColumn, true},
+		{"RoleDescriptionColumn", m.RoleDescriptionColumn, true},
+		{"PrivilegeDescriptionColumn", m.PrivilegeDescriptionColumn, true},
+		{"PrivilegeSoftDeleteColumn", m.PrivilegeSoftDeleteColumn, true},
+		{"RoleSoftDeleteColumn", m.RoleSoftDeleteColumn, true},
+	} {
+		if field.optional && field.value == "" {
+			continue
+		}
+		if !identifier.MatchString(field.value) {
+			return fmt.Errorf("%w: %s %q is not a valid identifier", ErrInvalidMapping, field.name, field.value)
+		}
+	}
+	if m.RoleSoftDeleteColumn != "" && m.RolesTable == "" {
+		return fmt.Errorf("%w: RoleSoftDeleteColumn needs a RolesTable", ErrInvalidMapping)
+	}
+	return nil
+}
+
+// check runs an empty select over every mapped table and column, so a name the
+// database does not know fails here rather than on the first request
+func (m Mapping) check(db *gorm.DB) error {
+	type probe struct {
+		table   string
+		columns []string
+	}
+	probes := []probe{
+		{m.PrivilegesTable, []string{m.PrivilegeKeyColumn, m.PrivilegeCodeColumn, m.PrivilegeDescriptionColumn, m.PrivilegeSoftDeleteColumn}},
+		{m.RolePrivilegesTable, []string{m.RolePrivilegeRoleColumn, m.RolePrivilegePrivilegeColumn}},
+	}
+	if m.RolesTable != "" {
+		probes = append(probes, probe{m.RolesTable, []string{m.RoleIDColumn, m.RoleNameColumn, m.RoleDescriptionColumn, m.RoleSoftDeleteColumn}})
+	}
+
+	for _, probe := range probes {
+		var columns []string
+		for _, column := range probe.columns {
+			if column != "" {
+				columns = append(columns, column)
+			}
+		}
+		table := m.table(probe.table)
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 0", strings.Join(columns, ", "), table)
+		if err := db.Exec(query).Error; err != nil {
+			return fmt.Errorf("%w: reading %s from %s: %v", ErrInvalidMapping, strings.Join(columns, ", "), table, err)
+		}
+	}
+	return nil
+}
+
+// table qualifies a table name with the schema, if any
+func (m Mapping) table(name string) string {
+	if m.Schema == "" {
+		return name
+	}
+	return m.Schema + "." + name
+}
+
+// live returns a condition keeping the rows of alias not marked deleted by column,
+// prefixed with " AND ", or "" without a soft-delete column
+func live(alias, column string) string {
+	if column == "" {
+		return ""
+	}
+	if alias != "" {
+		column = alias + "." + column
+	}
+	return " AND " + column + " IS NULL"
+}
@@ -0,0 +1,149 @@
+package rbacgorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hatmahat/go-rbac/rbac"
+	"gorm.io/gorm"
+)
+
+// legacyMapping maps the legacy schema created by newLegacyDB
+var legacyMapping = Mapping{
+	Schema:                       "legacy",
+	PrivilegesTable:              "permissions",
+	PrivilegeKeyColumn:           "name",
+	PrivilegeCodeColumn:          "name",
+	RolePrivilegesTable:          "role_permission",
+	RolePrivilegePrivilegeColumn: "permission_code",
+	RolesTable:                   "roles",
+	PrivilegeSoftDeleteColumn:    "deleted_at",
+	RoleSoftDeleteColumn:         "deleted_at",
+}
+
+// newLegacyDB creates a schema linking roles to permission names in an attached
+// "legacy" database, with soft-deleted permissions and roles
+func newLegacyDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := newEmptyDB(t)
+	execAll(t, db,
+		`ATTACH DATABASE ':memory:' AS legacy`,
+		`CREATE TABLE legacy.permissions (name TEXT PRIMARY KEY, deleted_at DATETIME)`,
+		`CREATE TABLE legacy.role_permission (role_id TEXT NOT NULL, permission_code TEXT NOT NULL, UNIQUE (role_id, permission_code))`,
+		`CREATE TABLE legacy.roles (id TEXT PRIMARY KEY, deleted_at DATETIME)`,
+		`INSERT INTO legacy.permissions (name, deleted_at) VALUES ('user:read', NULL), ('user:write', NULL), ('user:purge', CURRENT_TIMESTAMP)`,
+		`INSERT INTO legacy.roles (id, deleted_at) VALUES ('admin', NULL), ('empty', NULL), ('retired', CURRENT_TIMESTAMP)`,
+		`INSERT INTO legacy.role_permission (role_id, permission_code) VALUES ('admin', 'user:read'), ('admin', 'user:write'), ('admin', 'user:purge'), ('retired', 'user:read')`,
+	)
+	return db
+}
+
+func TestNewGormPrivilegeRepository_InvalidRolesTable(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("expected a panic with ErrInvalidMapping, got %v", err)
+		}
+	}()
+
+	NewGormPrivilegeRepository(newTestDB(t), WithRolesTable("roles r"))
+}
+
+func TestNewGormPrivilegeRepositoryWithMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping Mapping
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "default schema", mapping: Mapping{}},
+		{name: "legacy schema", mapping: legacyMapping},
+		{name: "invalid identifier", mapping: Mapping{PrivilegesTable: "privileges; DROP TABLE roles"}, wantErr: true},
+		{name: "invalid roles table option", opts: []Option{WithRolesTable("roles r")}, wantErr: true},
+		{name: "unknown table", mapping: Mapping{RolePrivilegesTable: "role_permission"}, wantErr: true},
+		{name: "unknown column", mapping: Mapping{PrivilegeCodeColumn: "name"}, wantErr: true},
+		{name: "unknown soft-delete column", mapping: Mapping{PrivilegeSoftDeleteColumn: "deleted_at"}, wantErr: true},
+		{name: "unknown schema", mapping: Mapping{Schema: "archive"}, wantErr: true},
+		{name: "soft-deleted roles without a roles table", mapping: Mapping{RoleSoftDeleteColumn: "deleted_at"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if tt.mapping.Schema == "legacy" {
+				db = newLegacyDB(t)
+			}
+
+			repo, err := NewGormPrivilegeRepositoryWithMapping(db, tt.mapping, tt.opts...)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMapping) || repo != nil {
+					t.Fatalf("expected ErrInvalidMapping, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, err := repo.FetchPrivilegesByRoleID(context.Background(), "admin"); err != nil || len(got) != 2 {
+				t.Errorf("expected 2 privileges, got %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestGormPrivilegeRepository_LegacyMapping(t *testing.T) {
+	db := newLegacyDB(t)
+	repo, err := NewGormPrivilegeRepositoryWithMapping(db, legacyMapping)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	// Soft-deleted permissions are not granted, soft-deleted roles do not exist even
+	// though "retired" still has a grant
+	want := map[string]bool{"user:read": true, "user:write": true}
+	if got, err := repo.FetchPrivilegesByRoleID(ctx, "admin"); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+	if _, err := repo.FetchPrivilegesByRoleID(ctx, "retired"); !errors.Is(err, rbac.ErrRoleNotFound) {
+		t.Errorf("expected ErrRoleNotFound, got %v", err)
+	}
+
+	got, err := repo.FetchPrivilegesByRoleIDs(ctx, []string{"admin", "empty", "retired"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantMany := map[string]map[string]bool{"admin": want, "empty": {}}
+	if !reflect.DeepEqual(got, wantMany) {
+		t.Errorf("got %v, want %v", got, wantMany)
+	}
+
+	// The grants of soft-deleted roles are not read when loading every role
+	var all []string
+	err = repo.FetchAll(ctx, func(roleID string, _ map[string]bool) error {
+		all = append(all, roleID)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(all, []string{"admin"}) {
+		t.Errorf("got %v, %v, want only admin", all, err)
+	}
+
+	// Writes go to the mapped tables too
+	if err := repo.ReplaceRolePrivileges(ctx, "empty", []string{"user:read"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := repo.FetchPrivilegesByRoleID(ctx, "empty"); err != nil || !got["user:read"] || len(got) != 1 {
+		t.Errorf("expected the written grant, got %v, %v", got, err)
+	}
+	if err := repo.ReplaceRolePrivileges(ctx, "empty", []string{"user:purge"}); !errors.Is(err, rbac.ErrUnknownPrivilege) {
+		t.Errorf("expected ErrUnknownPrivilege for a deleted permission, got %v", err)
+	}
+	if err := repo.DeleteRolePrivileges(ctx, "empty"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := repo.FetchPrivilegesByRoleID(ctx, "empty"); err != nil || len(got) != 0 {
+		t.Errorf("expected no grants, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,328 @@
+package rbacgorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultMigrationsTable is the table recording the applied schema migrations
+const DefaultMigrationsTable = "rbac_schema_migrations"
+
+// Migration is one versioned step of the rbacgorm schema
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *gorm.DB) error
+}
+
+// migrations are the schema changes in the order they were released. Released
+// migrations never change; each uses its own snapshot of the models.
+var migrations = []Migration{
+	{Version: 1, Description: "create roles, privileges, role_privileges and user_roles", Up: createSchema},
+}
+
+// migrationRecord is a row of the migrations table
+type migrationRecord struct {
+	Version     int `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+// Migrator brings a database to the latest rbacgorm schema. It upgrades tables
+// created by hand in place: missing tables are created, and missing columns and
+// indexes are added to existing ones without touching their data. The exception
+// are privileges and role_privileges tables with ids that are not integers, which
+// the database cannot generate: both are rebuilt, renumbering the privileges and
+// keeping their codes, descriptions and grants, while other columns are dropped.
+type Migrator struct {
+	db    *gorm.DB
+	table string
+}
+
+// MigratorOption configures a Migrator
+type MigratorOption func(*Migrator)
+
+// WithMigrationsTable names the table recording applied migrations, by default
+// DefaultMigrationsTable
+func WithMigrationsTable(table string) MigratorOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// NewMigrator creates a Migrator for db
+func NewMigrator(db *gorm.DB, opts ...MigratorOption) *Migrator {
+	m := &Migrator{db: db, table: DefaultMigrationsTable}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Migrate applies every pending migration to db with the default options
+func Migrate(ctx context.Context, db *gorm.DB) error {
+	return NewMigrator(db).Migrate(ctx)
+}
+
+// Migrate applies the pending migrations in order, each in its own transaction
+// together with its record, so a failed migration leaves the ones before it applied.
+// Do not run it from several instances at once.
+func (m *Migrator) Migrate(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Table(m.table).Create(&migrationRecord{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("rbacgorm: migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// Version returns the version of the latest applied migration, 0 if none. It
+// creates the migrations table if needed.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	db := m.db.WithContext(ctx)
+	if err := db.Table(m.table).AutoMigrate(&migrationRecord{}); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.Table(m.table).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Pending returns the migrations Migrate would apply
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// ensureTable creates the table of model, or adds the listed fields and indexes of
+// model an existing table lacks
+func ensureTable(tx *gorm.DB, model any, fields []string, indexes []string) error {
+	migrator := tx.Migrator()
+	if !migrator.HasTable(model) {
+		return migrator.CreateTable(model)
+	}
+
+	for _, field := range fields {
+		if !migrator.HasColumn(model, field) {
+			if err := migrator.AddColumn(model, field); err != nil {
+				return err
+			}
+		}
+	}
+	for _, index := range indexes {
+		if !migrator.HasIndex(model, index) {
+			if err := migrator.CreateIndex(model, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Version 1
+
+type roleV1 struct {
+	ID          string `gorm:"primaryKey;size:191"`
+	Name        string `gorm:"size:255;not null;default:''"`
+	Description string `gorm:"type:text;not null;default:''"`
+}
+
+func (roleV1) TableName() string { return "roles" }
+
+type privilegeV1 struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	Code        string `gorm:"size:191;not null;uniqueIndex:idx_privileges_code"`
+	Description string `gorm:"type:text;not null;default:''"`
+}
+
+func (privilegeV1) TableName() string { return "privileges" }
+
+type rolePrivilegeV1 struct {
+	RoleID      string `gorm:"primaryKey;size:191"`
+	PrivilegeID uint   `gorm:"primaryKey;index:idx_role_privileges_privilege_id"`
+}
+
+func (rolePrivilegeV1) TableName() string { return "role_privileges" }
+
+type userRoleV1 struct {
+	UserID string `gorm:"primaryKey;size:191"`
+	RoleID string `gorm:"primaryKey;size:191;index:idx_user_roles_role_id"`
+}
+
+func (userRoleV1) TableName() string { return "user_roles" }
+
+func createSchema(tx *gorm.DB) error {
+	if err := ensureTable(tx, &roleV1{}, []string{"Name", "Description"}, nil); err != nil {
+		return err
+	}
+	rebuilt, err := rebuildGrantTables(tx)
+	if err != nil {
+		return err
+	}
+	if err := ensureTable(tx, &privilegeV1{}, []string{"Description"}, []string{"idx_privileges_code"}); err != nil {
+		return err
+	}
+
+	// A hand-made role_privileges table may lack the composite primary key, which
+	// cannot be added in place; a unique index keeps grants unique instead
+	existed := !rebuilt && tx.Migrator().HasTable(&rolePrivilegeV1{})
+	if err := ensureTable(tx, &rolePrivilegeV1{}, nil, []string{"idx_role_privileges_privilege_id"}); err != nil {
+		return err
+	}
+	if existed && !tx.Migrator().HasIndex(&rolePrivilegeV1{}, "idx_role_privileges_grant") {
+		if err := tx.Exec("CREATE UNIQUE INDEX idx_role_privileges_grant ON role_privileges (role_id, privilege_id)").Error; err != nil {
+			return err
+		}
+	}
+
+	return ensureTable(tx, &userRoleV1{}, nil, []string{"idx_user_roles_role_id"})
+}
+
+// Tables rebuildGrantTables moves hand-made tables to while copying them
+const (
+	oldPrivilegesTable     = "rbac_old_privileges"
+	oldRolePrivilegesTable = "rbac_old_role_privileges"
+)
+
+// rebuildGrantTables recreates hand-made privileges and role_privileges tables whose
+// privilege ids are not integers, or whose grants have an id of their own, since the
+// database generates neither. Grants are copied by privilege code. It reports
+// whether the tables were rebuilt.
+func rebuildGrantTables(tx *gorm.DB) (bool, error) {
+	migrator := tx.Migrator()
+	hasPrivileges := migrator.HasTable(&privilegeV1{})
+	hasGrants := migrator.HasTable(&rolePrivilegeV1{})
+
+	rebuild := false
+	if hasPrivileges {
+		integer, err := integerColumn(tx, &privilegeV1{}, "id")
+		if err != nil {
+			return false, err
+		}
+		rebuild = !integer
+	}
+	if hasGrants && !rebuild {
+		integer, err := integerColumn(tx, &rolePrivilegeV1{}, "privilege_id")
+		if err != nil {
+			return false, err
+		}
+		rebuild = !integer || migrator.HasColumn(&rolePrivilegeV1{}, "id")
+	}
+	if !rebuild {
+		return false, nil
+	}
+
+	// Index names are shared by the schema, so the ones the new tables create must
+	// not stay behind on the old ones
+	moves := []struct {
+		exists  bool
+		model   any
+		to      string
+		indexes []string
+	}{
+		{hasPrivileges, &privilegeV1{}, oldPrivilegesTable, []string{"idx_privileges_code"}},
+		{hasGrants, &rolePrivilegeV1{}, oldRolePrivilegesTable, []string{"idx_role_privileges_privilege_id", "idx_role_privileges_grant"}},
+	}
+	for _, move := range moves {
+		if !move.exists {
+			continue
+		}
+		for _, index := range move.indexes {
+			if migrator.HasIndex(move.model, index) {
+				if err := migrator.DropIndex(move.model, index); err != nil {
+					return false, err
+				}
+			}
+		}
+		if err := migrator.RenameTable(move.model, move.to); err != nil {
+			return false, err
+		}
+	}
+
+	if err := migrator.CreateTable(&privilegeV1{}, &rolePrivilegeV1{}); err != nil {
+		return false, err
+	}
+	// Grants of privileges that did not exist cannot be copied
+	if hasPrivileges {
+		if err := copyGrantTables(tx, hasGrants); err != nil {
+			return false, err
+		}
+	}
+
+	for _, move := range moves {
+		if move.exists {
+			if err := migrator.DropTable(move.to); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// copyGrantTables fills the rebuilt tables from the old ones
+func copyGrantTables(tx *gorm.DB, hasGrants bool) error {
+	description := "''"
+	if tx.Migrator().HasColumn(oldPrivilegesTable, "description") {
+		description = "MAX(COALESCE(description, ''))"
+	}
+	err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO privileges (code, description)
+		SELECT code, %s FROM %s GROUP BY code ORDER BY MIN(id)
+	`, description, oldPrivilegesTable)).Error
+	if err != nil || !hasGrants {
+		return err
+	}
+
+	return tx.Exec(fmt.Sprintf(`
+		INSERT INTO role_privileges (role_id, privilege_id)
+		SELECT DISTINCT rp.role_id, p.id
+		FROM %s rp
+		JOIN %s op ON op.id = rp.privilege_id
+		JOIN privileges p ON p.code = op.code
+	`, oldRolePrivilegesTable, oldPrivilegesTable)).Error
+}
+
+// integerColumn reports whether the column of model's table has an integer type
+func integerColumn(tx *gorm.DB, model any, column string) (bool, error) {
+	columns, err := tx.Migrator().ColumnTypes(model)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range columns {
+		if strings.EqualFold(c.Name(), column) {
+			return strings.Contains(strings.ToUpper(c.DatabaseTypeName()), "INT"), nil
+		}
+	}
+	return false, fmt.Errorf("rbacgorm: column %s not found", column)
+}
@@ -0,0 +1,171 @@
+package rbacgorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hatmahat/go-rbac/rbac"
+	"gorm.io/gorm"
+)
+
+func TestMigrator_Migrate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []string
+	}{
+		{
+			name: "empty database",
+		},
+		{
+			// The schema the example used to create, with text ids the database
+			// does not generate
+			name: "hand-written schema with text ids",
+			setup: []string{
+				`CREATE TABLE privileges (id TEXT PRIMARY KEY, code TEXT NOT NULL)`,
+				`CREATE TABLE role_privileges (id TEXT PRIMARY KEY, role_id TEXT NOT NULL, privilege_id TEXT NOT NULL)`,
+				`INSERT INTO privileges (id, code) VALUES ('p1', 'read:compliance'), ('p2', 'write:compliance')`,
+				`INSERT INTO role_privileges (id, role_id, privilege_id) VALUES ('rp1', 'admin', 'p1'), ('rp2', 'auditor', 'p1')`,
+			},
+		},
+		{
+			name: "hand-written schema with generated ids",
+			setup: []string{
+				`CREATE TABLE roles (id TEXT PRIMARY KEY)`,
+				`CREATE TABLE privileges (id INTEGER PRIMARY KEY, code TEXT NOT NULL)`,
+				`CREATE TABLE role_privileges (role_id TEXT NOT NULL, privilege_id INTEGER NOT NULL)`,
+				`INSERT INTO roles (id) VALUES ('admin')`,
+				`INSERT INTO privileges (id, code) VALUES (1, 'read:compliance')`,
+				`INSERT INTO role_privileges (role_id, privilege_id) VALUES ('admin', 1)`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newEmptyDB(t)
+			execAll(t, db, tt.setup...)
+			ctx := context.Background()
+			migrator := NewMigrator(db)
+
+			if err := migrator.Migrate(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// Running again is a no-op
+			if err := migrator.Migrate(ctx); err != nil {
+				t.Fatalf("unexpected error on second run: %v", err)
+			}
+			if version, err := migrator.Version(ctx); err != nil || version != 1 {
+				t.Errorf("expected version 1, got %d, %v", version, err)
+			}
+			if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+				t.Errorf("expected nothing pending, got %v, %v", pending, err)
+			}
+
+			for _, model := range []any{&Role{}, &Privilege{}, &RolePrivilege{}, &UserRole{}} {
+				if !db.Migrator().HasTable(model) {
+					t.Errorf("missing table for %T", model)
+				}
+			}
+			for model, columns := range map[any][]string{&Role{}: {"name", "description"}, &Privilege{}: {"description"}} {
+				for _, column := range columns {
+					if !db.Migrator().HasColumn(model, column) {
+						t.Errorf("missing column %s for %T", column, model)
+					}
+				}
+			}
+
+			// Existing data survives, and the schema works with the repository
+			// and the role manager
+			repo := NewGormPrivilegeRepository(db, WithRolesTable("roles"))
+			manager := NewGormRoleManager(db, nil)
+			if len(tt.setup) > 0 {
+				got, err := NewGormPrivilegeRepository(db).FetchPrivilegesByRoleID(ctx, "admin")
+				if err != nil || !got["read:compliance"] {
+					t.Errorf("expected existing grant, got %v, %v", got, err)
+				}
+			}
+			if err := manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "read:users"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := manager.CreateRole(ctx, rbac.Role{ID: "viewer", Name: "Viewer"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := manager.GrantPrivileges(ctx, "viewer", "read:users"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, err := repo.FetchPrivilegesByRoleID(ctx, "viewer"); err != nil || !got["read:users"] {
+				t.Errorf("expected granted privilege, got %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestMigrator_Constraints(t *testing.T) {
+	db := newEmptyDB(t)
+	if err := Migrate(context.Background(), db); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	execAll(t, db,
+		`INSERT INTO privileges (code) VALUES ('read:users')`,
+		`INSERT INTO role_privileges (role_id, privilege_id) VALUES ('viewer', 1)`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES ('alice', 'viewer')`,
+	)
+
+	duplicates := []string{
+		`INSERT INTO privileges (code) VALUES ('read:users')`,
+		`INSERT INTO role_privileges (role_id, privilege_id) VALUES ('viewer', 1)`,
+		`INSERT INTO user_roles (user_id, role_id) VALUES ('alice', 'viewer')`,
+	}
+	for _, stmt := range duplicates {
+		if err := db.Exec(stmt).Error; err == nil {
+			t.Errorf("expected %q to violate a unique constraint", stmt)
+		}
+	}
+
+	// Deleting a role also removes its assignments
+	execAll(t, db, `INSERT INTO roles (id) VALUES ('viewer')`)
+	if err := NewGormRoleManager(db, nil).DeleteRole(context.Background(), "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var assigned int64
+	if err := db.Model(&UserRole{}).Count(&assigned).Error; err != nil || assigned != 0 {
+		t.Errorf("expected no user roles left, got %d, %v", assigned, err)
+	}
+}
+
+func TestMigrator_Failure(t *testing.T) {
+	db := newEmptyDB(t)
+	ctx := context.Background()
+
+	errBroken := errors.New("broken")
+	saved := migrations
+	migrations = append(append([]Migration(nil), saved...), Migration{
+		Version:     len(saved) + 1,
+		Description: "broken",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec(`CREATE TABLE half_done (id INTEGER)`).Error; err != nil {
+				return err
+			}
+			return errBroken
+		},
+	})
+	defer func() { migrations = saved }()
+
+	migrator := NewMigrator(db, WithMigrationsTable("custom_migrations"))
+	if err := migrator.Migrate(ctx); !errors.Is(err, errBroken) {
+		t.Fatalf("expected the migration error, got %v", err)
+	}
+
+	// The migrations before the broken one stay applied, the broken one is rolled back
+	if version, err := migrator.Version(ctx); err != nil || version != len(saved) {
+		t.Errorf("expected version %d, got %d, %v", len(saved), version, err)
+	}
+	if db.Migrator().HasTable("half_done") {
+		t.Error("the failed migration was not rolled back")
+	}
+	if !db.Migrator().HasTable("custom_migrations") || db.Migrator().HasTable(DefaultMigrationsTable) {
+		t.Error("expected the custom migrations table to be used")
+	}
+}
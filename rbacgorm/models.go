@@ -0,0 +1,39 @@
+package rbacgorm
+
+// The models below describe the schema read by GormPrivilegeRepository and written by
+// GormRoleManager, as created by Migrate. Use them for your own queries, or to add
+// the tables to an existing AutoMigrate call.
+
+// Role is a row of the roles table
+type Role struct {
+	ID          string `gorm:"primaryKey;size:191"`
+	Name        string `gorm:"size:255;not null;default:''"`
+	Description string `gorm:"type:text;not null;default:''"`
+}
+
+func (Role) TableName() string { return "roles" }
+
+// Privilege is a row of the privileges table. Codes are unique.
+type Privilege struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	Code        string `gorm:"size:191;not null;uniqueIndex:idx_privileges_code"`
+	Description string `gorm:"type:text;not null;default:''"`
+}
+
+func (Privilege) TableName() string { return "privileges" }
+
+// RolePrivilege grants a privilege to a role. A role holds each privilege once.
+type RolePrivilege struct {
+	RoleID      string `gorm:"primaryKey;size:191"`
+	PrivilegeID uint   `gorm:"primaryKey;index:idx_role_privileges_privilege_id"`
+}
+
+func (RolePrivilege) TableName() string { return "role_privileges" }
+
+// UserRole assigns a role to a user. A user holds each role once.
+type UserRole struct {
+	UserID string `gorm:"primaryKey;size:191"`
+	RoleID string `gorm:"primaryKey;size:191;index:idx_user_roles_role_id"`
+}
+
+func (UserRole) TableName() string { return "user_roles" }
@@ -0,0 +1,127 @@
+package rbacgorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hatmahat/go-rbac/rbac"
+	"gorm.io/gorm"
+)
+
+var _ rbac.PrivilegeWriter = (*GormPrivilegeRepository)(nil)
+
+// CreateRolePrivileges grants privileges to a role without any role_privileges rows,
+// returning rbac.ErrRoleExists otherwise. Every code must already be registered in
+// the privileges table.
+func (g *GormPrivilegeRepository) CreateRolePrivileges(ctx context.Context, roleID string, privileges []string) error {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	m := g.mapping
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Table(m.table(m.RolePrivilegesTable)).Where(m.RolePrivilegeRoleColumn+" = ?", roleID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", rbac.ErrRoleExists, roleID)
+		}
+		return g.insertGrants(tx, roleID, privileges)
+	})
+}
+
+// ReplaceRolePrivileges replaces the role_privileges rows of a role in one
+// transaction. Every code must already be registered in the privileges table.
+func (g *GormPrivilegeRepository) ReplaceRolePrivileges(ctx context.Context, roleID string, privileges []string) error {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := g.deleteGrants(tx, roleID); err != nil {
+			return err
+		}
+		return g.insertGrants(tx, roleID, privileges)
+	})
+}
+
+// DeleteRolePrivileges deletes the role_privileges rows of a role. The role itself,
+// if there is a roles table, is left alone.
+func (g *GormPrivilegeRepository) DeleteRolePrivileges(ctx context.Context, roleID string) error {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	return g.deleteGrants(g.db.WithContext(ctx), roleID)
+}
+
+// deleteGrants deletes the role_privileges rows of a role
+func (g *GormPrivilegeRepository) deleteGrants(tx *gorm.DB, roleID string) error {
+	m := g.mapping
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", m.table(m.RolePrivilegesTable), m.RolePrivilegeRoleColumn)
+	return tx.Exec(query, roleID).Error
+}
+
+// insertGrants links a role to the privileges with the given codes it is not linked
+// to yet, failing with rbac.ErrUnknownPrivilege if any code is not in the privileges
+// table
+func (g *GormPrivilegeRepository) insertGrants(tx *gorm.DB, roleID string, privileges []string) error {
+	codes := uniqueCodes(privileges)
+	if len(codes) == 0 {
+		return nil
+	}
+
+	m := g.mapping
+	var known []string
+	err := tx.Table(m.table(m.PrivilegesTable)).
+		Where(m.PrivilegeCodeColumn+" IN ?"+live("", m.PrivilegeSoftDeleteColumn), codes).
+		Distinct().Pluck(m.PrivilegeCodeColumn, &known).Error
+	if err != nil {
+		return err
+	}
+	if len(known) != len(codes) {
+		return fmt.Errorf("%w: %s", rbac.ErrUnknownPrivilege, strings.Join(missingCodes(codes, known), ", "))
+	}
+
+	// Selecting the keys keeps the insert independent of their type
+	rolePrivileges := m.table(m.RolePrivilegesTable)
+	query := fmt.Sprintf(`
+		INSERT INTO %s (%s, %s)
+		SELECT ?, p.%s FROM %s p
+		WHERE p.%s IN ?%s
+		AND p.%s NOT IN (SELECT %s FROM %s WHERE %s = ?)
+	`, rolePrivileges, m.RolePrivilegeRoleColumn, m.RolePrivilegePrivilegeColumn,
+		m.PrivilegeKeyColumn, m.table(m.PrivilegesTable),
+		m.PrivilegeCodeColumn, live("p", m.PrivilegeSoftDeleteColumn),
+		m.PrivilegeKeyColumn, m.RolePrivilegePrivilegeColumn, rolePrivileges, m.RolePrivilegeRoleColumn)
+	return tx.Exec(query, roleID, codes, roleID).Error
+}
+
+// uniqueCodes returns privileges without duplicates, in their original order
+func uniqueCodes(privileges []string) []string {
+	seen := make(map[string]bool, len(privileges))
+	codes := make([]string, 0, len(privileges))
+	for _, code := range privileges {
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// missingCodes returns the codes not in known
+func missingCodes(codes, known []string) []string {
+	found := make(map[string]bool, len(known))
+	for _, code := range known {
+		found[code] = true
+	}
+
+	var missing []string
+	for _, code := range codes {
+		if !found[code] {
+			missing = append(missing, code)
+		}
+	}
+	return missing
+}
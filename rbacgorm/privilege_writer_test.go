@@ -0,0 +1,117 @@
+package rbacgorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hatmahat/go-rbac/rbac"
+)
+
+func TestGormPrivilegeRepository_Writer(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(ctx context.Context, repo *GormPrivilegeRepository) error
+		roleID  string
+		want    map[string]bool
+		wantErr error
+	}{
+		{
+			name: "create new role",
+			write: func(ctx context.Context, repo *GormPrivilegeRepository) error {
+				return repo.CreateRolePrivileges(ctx, "viewer", []string{"user:read", "user:read"})
+			},
+			roleID: "viewer",
+			want:   map[string]bool{"user:read": true},
+		},
+		{
+			name: "create existing role",
+			write: func(ctx context.Context, repo *GormPrivilegeRepository) error {
+				return repo.CreateRolePrivileges(ctx, "admin", []string{"user:read"})
+			},
+			roleID:  "admin",
+			want:    map[string]bool{"user:read": true, "user:write": true},
+			wantErr: rbac.ErrRoleExists,
+		},
+		{
+			name: "replace",
+			write: func(ctx context.Context, repo *GormPrivilegeRepository) error {
+				return repo.ReplaceRolePrivileges(ctx, "admin", []string{"user:write"})
+			},
+			roleID: "admin",
+			want:   map[string]bool{"user:write": true},
+		},
+		{
+			name: "replace with unknown code rolls back",
+			write: func(ctx context.Context, repo *GormPrivilegeRepository) error {
+				return repo.ReplaceRolePrivileges(ctx, "admin", []string{"user:write", "user:fly"})
+			},
+			roleID:  "admin",
+			want:    map[string]bool{"user:read": true, "user:write": true},
+			wantErr: rbac.ErrUnknownPrivilege,
+		},
+		{
+			name: "replace with nothing",
+			write: func(ctx context.Context, repo *GormPrivilegeRepository) error {
+				return repo.ReplaceRolePrivileges(ctx, "admin", nil)
+			},
+			roleID: "admin",
+			want:   map[string]bool{},
+		},
+		{
+			name: "delete",
+			write: func(ctx context.Context, repo *GormPrivilegeRepository) error {
+				return repo.DeleteRolePrivileges(ctx, "admin")
+			},
+			roleID: "admin",
+			want:   map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewGormPrivilegeRepository(newTestDB(t))
+			ctx := context.Background()
+
+			err := tt.write(ctx, repo)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			got, err := repo.FetchPrivilegesByRoleID(ctx, tt.roleID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGormPrivilegeRepository_WriteThrough(t *testing.T) {
+	repo := NewGormPrivilegeRepository(newTestDB(t))
+	svc := rbac.NewRBACService(repo, 0, nil, rbac.WithPrivilegeWriter(repo))
+	defer svc.Close()
+	ctx := context.Background()
+
+	if err := svc.SetNewRolePrivileges(ctx, "viewer", []string{"user:read"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A second service reading the same database sees the change
+	other := rbac.NewRBACService(repo, 0, nil)
+	defer other.Close()
+	if ok, err := other.HasPrivilege(ctx, "viewer", "user:read"); err != nil || !ok {
+		t.Errorf("expected persisted privilege, got %v, %v", ok, err)
+	}
+
+	err := svc.SetNewRolePrivileges(ctx, "viewer", []string{"user:fly"})
+	if !errors.Is(err, rbac.ErrUnknownPrivilege) {
+		t.Fatalf("expected ErrUnknownPrivilege, got %v", err)
+	}
+	if ok, _ := svc.HasPrivilege(ctx, "viewer", "user:read"); !ok {
+		t.Error("a failed write should leave the cache alone")
+	}
+}
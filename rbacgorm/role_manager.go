@@ -0,0 +1,346 @@
+package rbacgorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hatmahat/go-rbac/rbac"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormRoleManager implements rbac.RoleManager on the tables read by
+// GormPrivilegeRepository. By default these are the roles, privileges and
+// role_privileges tables created by Migrate; NewGormRoleManagerWithMapping uses the
+// tables and columns of a Mapping instead. Privilege keys must be generated by the
+// database or equal to the codes.
+type GormRoleManager struct {
+	db      *gorm.DB
+	mapping Mapping
+	grants  *GormPrivilegeRepository
+	service rbac.RBACService
+}
+
+var _ rbac.RoleManager = (*GormRoleManager)(nil)
+
+// NewGormRoleManager creates a GormRoleManager on the schema created by Migrate.
+// After every committed change to a role's privileges it invalidates the role in
+// service, which also reaches other instances through the service's
+// InvalidationBus. service may be nil. opts configure the queries as for
+// NewGormPrivilegeRepository; the manager needs a roles table, so an empty one
+// panics with ErrInvalidMapping.
+func NewGormRoleManager(db *gorm.DB, service rbac.RBACService, opts ...Option) *GormRoleManager {
+	m, err := newGormRoleManager(db, NewGormPrivilegeRepository(db, opts...), service)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewGormRoleManagerWithMapping creates a GormRoleManager on the tables and columns
+// named by mapping, as NewGormPrivilegeRepositoryWithMapping does. It fails with
+// ErrInvalidMapping if mapping has no RolesTable.
+func NewGormRoleManagerWithMapping(db *gorm.DB, mapping Mapping, service rbac.RBACService, opts ...Option) (*GormRoleManager, error) {
+	grants, err := NewGormPrivilegeRepositoryWithMapping(db, mapping, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newGormRoleManager(db, grants, service)
+}
+
+func newGormRoleManager(db *gorm.DB, grants *GormPrivilegeRepository, service rbac.RBACService) (*GormRoleManager, error) {
+	if grants.mapping.RolesTable == "" {
+		return nil, fmt.Errorf("%w: GormRoleManager needs a RolesTable", ErrInvalidMapping)
+	}
+	return &GormRoleManager{db: db, mapping: grants.mapping, grants: grants, service: service}, nil
+}
+
+// CreateRole creates a role without privileges, returning rbac.ErrRoleExists if its
+// ID is taken, also by a soft-deleted role
+func (m *GormRoleManager) CreateRole(ctx context.Context, role rbac.Role) error {
+	values := map[string]any{m.mapping.RoleIDColumn: role.ID}
+	setColumn(values, m.mapping.RoleNameColumn, role.Name)
+	setColumn(values, m.mapping.RoleDescriptionColumn, role.Description)
+
+	err := m.transaction(ctx, func(tx *gorm.DB) error {
+		created, err := insertNew(tx, m.mapping.table(m.mapping.RolesTable), values)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return fmt.Errorf("%w: %s", rbac.ErrRoleExists, role.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The service may remember the role as not found
+	return m.invalidate(ctx, role.ID)
+}
+
+// RenameRole changes the display name of a role. Its ID, and so its cached
+// privileges, stay the same. It fails with ErrInvalidMapping without a
+// RoleNameColumn.
+func (m *GormRoleManager) RenameRole(ctx context.Context, roleID string, name string) error {
+	if m.mapping.RoleNameColumn == "" {
+		return fmt.Errorf("%w: renaming roles needs a RoleNameColumn", ErrInvalidMapping)
+	}
+	return m.transaction(ctx, func(tx *gorm.DB) error {
+		if err := m.requireRole(tx, roleID); err != nil {
+			return err
+		}
+		return m.roles(tx).Where(m.mapping.RoleIDColumn+" = ?", roleID).Update(m.mapping.RoleNameColumn, name).Error
+	})
+}
+
+// DeleteRole deletes a role together with its grants and, if there is a user_roles
+// table, its assignments to users. With a RoleSoftDeleteColumn the role is marked
+// deleted instead, and its ID stays taken.
+func (m *GormRoleManager) DeleteRole(ctx context.Context, roleID string) error {
+	mp := m.mapping
+	err := m.transaction(ctx, func(tx *gorm.DB) error {
+		if err := m.requireRole(tx, roleID); err != nil {
+			return err
+		}
+		if err := m.grants.deleteGrants(tx, roleID); err != nil {
+			return err
+		}
+		userRoles := mp.table(UserRole{}.TableName())
+		if tx.Migrator().HasTable(userRoles) {
+			if err := tx.Exec("DELETE FROM "+userRoles+" WHERE role_id = ?", roleID).Error; err != nil {
+				return err
+			}
+		}
+
+		roles := mp.table(mp.RolesTable)
+		if mp.RoleSoftDeleteColumn != "" {
+			query := fmt.Sprintf("UPDATE %s SET %s = CURRENT_TIMESTAMP WHERE %s = ?", roles, mp.RoleSoftDeleteColumn, mp.RoleIDColumn)
+			return tx.Exec(query, roleID).Error
+		}
+		return tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", roles, mp.RoleIDColumn), roleID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.invalidate(ctx, roleID)
+}
+
+// RegisterPrivilege adds a privilege code, returning rbac.ErrPrivilegeExists if it
+// is already registered. Concurrent registrations of a code are told apart by the
+// unique constraint on the code column, which Migrate creates.
+func (m *GormRoleManager) RegisterPrivilege(ctx context.Context, privilege rbac.Privilege) error {
+	values := map[string]any{m.mapping.PrivilegeCodeColumn: privilege.Code}
+	setColumn(values, m.mapping.PrivilegeDescriptionColumn, privilege.Description)
+
+	return m.transaction(ctx, func(tx *gorm.DB) error {
+		created, err := insertNew(tx, m.mapping.table(m.mapping.PrivilegesTable), values)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return fmt.Errorf("%w: %s", rbac.ErrPrivilegeExists, privilege.Code)
+		}
+		return nil
+	})
+}
+
+// GrantPrivileges grants registered privileges to a role. Codes the role already
+// holds are left as they are.
+func (m *GormRoleManager) GrantPrivileges(ctx context.Context, roleID string, codes ...string) error {
+	err := m.transaction(ctx, func(tx *gorm.DB) error {
+		if err := m.requireRole(tx, roleID); err != nil {
+			return err
+		}
+		return m.grants.insertGrants(tx, roleID, codes)
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.invalidate(ctx, roleID)
+}
+
+// RevokePrivileges revokes privileges from a role. Codes the role does not hold are
+// ignored.
+func (m *GormRoleManager) RevokePrivileges(ctx context.Context, roleID string, codes ...string) error {
+	mp := m.mapping
+	err := m.transaction(ctx, func(tx *gorm.DB) error {
+		if err := m.requireRole(tx, roleID); err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		query := fmt.Sprintf(`
+			DELETE FROM %s
+			WHERE %s = ?
+			AND %s IN (SELECT %s FROM %s WHERE %s IN ?)
+		`, mp.table(mp.RolePrivilegesTable), mp.RolePrivilegeRoleColumn,
+			mp.RolePrivilegePrivilegeColumn, mp.PrivilegeKeyColumn, mp.table(mp.PrivilegesTable), mp.PrivilegeCodeColumn)
+		return tx.Exec(query, roleID, codes).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.invalidate(ctx, roleID)
+}
+
+// ListRoles lists roles ordered by ID
+func (m *GormRoleManager) ListRoles(ctx context.Context, page rbac.Page) ([]rbac.Role, int, error) {
+	ctx, cancel := m.grants.withTimeout(ctx)
+	defer cancel()
+
+	mp := m.mapping
+	query := m.roles(m.db.WithContext(ctx)).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	roles := []rbac.Role{}
+	columns := fmt.Sprintf("%s AS id, %s AS name, %s AS description",
+		mp.RoleIDColumn, orEmpty("", mp.RoleNameColumn), orEmpty("", mp.RoleDescriptionColumn))
+	err := paginate(query, page).Select(columns).Order(mp.RoleIDColumn).Scan(&roles).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return roles, int(total), nil
+}
+
+// ListPrivileges lists registered privileges ordered by code
+func (m *GormRoleManager) ListPrivileges(ctx context.Context, page rbac.Page) ([]rbac.Privilege, int, error) {
+	ctx, cancel := m.grants.withTimeout(ctx)
+	defer cancel()
+
+	mp := m.mapping
+	query := m.db.WithContext(ctx).Table(mp.table(mp.PrivilegesTable))
+	if mp.PrivilegeSoftDeleteColumn != "" {
+		query = query.Where(mp.PrivilegeSoftDeleteColumn + " IS NULL")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	privileges := []rbac.Privilege{}
+	columns := fmt.Sprintf("%s AS code, %s AS description", mp.PrivilegeCodeColumn, orEmpty("", mp.PrivilegeDescriptionColumn))
+	err := paginate(query, page).Select(columns).Order(mp.PrivilegeCodeColumn).Scan(&privileges).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return privileges, int(total), nil
+}
+
+// ListRolePrivileges lists the privileges granted to a role ordered by code
+func (m *GormRoleManager) ListRolePrivileges(ctx context.Context, roleID string, page rbac.Page) ([]rbac.Privilege, int, error) {
+	ctx, cancel := m.grants.withTimeout(ctx)
+	defer cancel()
+
+	mp := m.mapping
+	db := m.db.WithContext(ctx)
+	if err := m.requireRole(db, roleID); err != nil {
+		return nil, 0, err
+	}
+
+	query := db.Table(mp.table(mp.PrivilegesTable)+" p").
+		Joins(fmt.Sprintf("JOIN %s rp ON p.%s = rp.%s", mp.table(mp.RolePrivilegesTable), mp.PrivilegeKeyColumn, mp.RolePrivilegePrivilegeColumn)).
+		Where("rp."+mp.RolePrivilegeRoleColumn+" = ?"+live("p", mp.PrivilegeSoftDeleteColumn), roleID).
+		Session(&gorm.Session{}) // reused for the count and the page
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	privileges := []rbac.Privilege{}
+	columns := fmt.Sprintf("p.%s AS code, %s AS description", mp.PrivilegeCodeColumn, orEmpty("p", mp.PrivilegeDescriptionColumn))
+	err := paginate(query, page).Select(columns).Order("p." + mp.PrivilegeCodeColumn).Scan(&privileges).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return privileges, int(total), nil
+}
+
+// transaction runs fn in a transaction bounded by the query timeout
+func (m *GormRoleManager) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	ctx, cancel := m.grants.withTimeout(ctx)
+	defer cancel()
+
+	return m.db.WithContext(ctx).Transaction(fn)
+}
+
+// invalidate drops a role from the service's cache once a change is committed
+func (m *GormRoleManager) invalidate(ctx context.Context, roleID string) error {
+	if m.service == nil {
+		return nil
+	}
+	return m.service.InvalidateTenantRole(ctx, "", roleID)
+}
+
+// roles starts a query on the live rows of the roles table
+func (m *GormRoleManager) roles(db *gorm.DB) *gorm.DB {
+	query := db.Table(m.mapping.table(m.mapping.RolesTable))
+	if m.mapping.RoleSoftDeleteColumn != "" {
+		query = query.Where(m.mapping.RoleSoftDeleteColumn + " IS NULL")
+	}
+	return query
+}
+
+// requireRole returns a RoleNotFoundError unless the roles table holds roleID
+func (m *GormRoleManager) requireRole(db *gorm.DB, roleID string) error {
+	var count int64
+	err := m.roles(db).Where(m.mapping.RoleIDColumn+" = ?", roleID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return &rbac.RoleNotFoundError{RoleID: roleID}
+	}
+	return nil
+}
+
+// insertNew inserts values into table unless that violates a unique constraint,
+// and reports whether it did. Unlike a lookup before the insert, this cannot race
+// with a concurrent insert of the same row.
+func insertNew(tx *gorm.DB, table string, values map[string]any) (bool, error) {
+	result := tx.Table(table).Clauses(clause.OnConflict{DoNothing: true}).Create(values)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// setColumn sets values[column] unless the column is not mapped
+func setColumn(values map[string]any, column string, value string) {
+	if column != "" {
+		values[column] = value
+	}
+}
+
+// orEmpty returns the column of alias, or an empty string literal if it is not
+// mapped
+func orEmpty(alias, column string) string {
+	if column == "" {
+		return "''"
+	}
+	if alias != "" {
+		return alias + "." + column
+	}
+	return column
+}
+
+// paginate applies page to query, defaulting the limit to rbac.DefaultPageLimit
+func paginate(query *gorm.DB, page rbac.Page) *gorm.DB {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = rbac.DefaultPageLimit
+	}
+	return query.Offset(max(page.Offset, 0)).Limit(limit)
+}
@@ -0,0 +1,295 @@
+package rbacgorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatmahat/go-rbac/rbac"
+)
+
+func TestGormRoleManager_Roles(t *testing.T) {
+	manager := NewGormRoleManager(newTestDB(t), nil)
+	ctx := context.Background()
+
+	if err := manager.CreateRole(ctx, rbac.Role{ID: "viewer", Name: "Viewer", Description: "Read only"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.CreateRole(ctx, rbac.Role{ID: "viewer"}); !errors.Is(err, rbac.ErrRoleExists) {
+		t.Fatalf("expected ErrRoleExists, got %v", err)
+	}
+	if err := manager.RenameRole(ctx, "viewer", "Reader"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.DeleteRole(ctx, "empty"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		page      rbac.Page
+		want      []rbac.Role
+		wantTotal int
+	}{
+		{
+			name: "default page",
+			want: []rbac.Role{
+				{ID: "admin"},
+				{ID: "viewer", Name: "Reader", Description: "Read only"},
+			},
+			wantTotal: 2,
+		},
+		{
+			name:      "limited",
+			page:      rbac.Page{Limit: 1},
+			want:      []rbac.Role{{ID: "admin"}},
+			wantTotal: 2,
+		},
+		{
+			name:      "offset",
+			page:      rbac.Page{Offset: 1, Limit: 1},
+			want:      []rbac.Role{{ID: "viewer", Name: "Reader", Description: "Read only"}},
+			wantTotal: 2,
+		},
+		{
+			name:      "past the end",
+			page:      rbac.Page{Offset: 5},
+			want:      []rbac.Role{},
+			wantTotal: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := manager.ListRoles(ctx, tt.page)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("expected %v of %d, got %v of %d", tt.want, tt.wantTotal, got, total)
+			}
+		})
+	}
+}
+
+func TestGormRoleManager_UnknownRole(t *testing.T) {
+	manager := NewGormRoleManager(newTestDB(t), nil)
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"rename": func() error { return manager.RenameRole(ctx, "ghost", "Ghost") },
+		"delete": func() error { return manager.DeleteRole(ctx, "ghost") },
+		"grant":  func() error { return manager.GrantPrivileges(ctx, "ghost", "user:read") },
+		"revoke": func() error { return manager.RevokePrivileges(ctx, "ghost", "user:read") },
+		"list": func() error {
+			_, _, err := manager.ListRolePrivileges(ctx, "ghost", rbac.Page{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); !errors.Is(err, rbac.ErrRoleNotFound) {
+				t.Errorf("expected ErrRoleNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGormRoleManager_Privileges(t *testing.T) {
+	manager := NewGormRoleManager(newTestDB(t), nil)
+	ctx := context.Background()
+
+	if err := manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "user:delete", Description: "Delete users"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "user:read"}); !errors.Is(err, rbac.ErrPrivilegeExists) {
+		t.Fatalf("expected ErrPrivilegeExists, got %v", err)
+	}
+	if err := manager.GrantPrivileges(ctx, "empty", "user:fly"); !errors.Is(err, rbac.ErrUnknownPrivilege) {
+		t.Fatalf("expected ErrUnknownPrivilege, got %v", err)
+	}
+
+	if err := manager.GrantPrivileges(ctx, "empty", "user:read", "user:delete"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Granting again changes nothing
+	if err := manager.GrantPrivileges(ctx, "empty", "user:read"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.RevokePrivileges(ctx, "empty", "user:read", "user:write"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, total, err := manager.ListRolePrivileges(ctx, "empty", rbac.Page{})
+	want := []rbac.Privilege{{Code: "user:delete", Description: "Delete users"}}
+	if err != nil || total != 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v of %d, %v", want, got, total, err)
+	}
+
+	all, total, err := manager.ListPrivileges(ctx, rbac.Page{Offset: 1, Limit: 1})
+	want = []rbac.Privilege{{Code: "user:read"}}
+	if err != nil || total != 3 || !reflect.DeepEqual(all, want) {
+		t.Errorf("expected %v, got %v of %d, %v", want, all, total, err)
+	}
+}
+
+func TestGormRoleManager_KeepsServiceInSync(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewGormPrivilegeRepository(db, WithRolesTable("roles"))
+	svc := rbac.NewRBACService(repo, 0, nil, rbac.WithNegativeCacheTTL(time.Hour))
+	defer svc.Close()
+	manager := NewGormRoleManager(db, svc)
+	ctx := context.Background()
+
+	// Cache the role, and an unknown role as not found
+	if ok, _ := svc.HasPrivilege(ctx, "admin", "user:read"); !ok {
+		t.Fatal("expected admin to read users")
+	}
+	if _, err := svc.GetRolePrivileges(ctx, "auditor"); !errors.Is(err, rbac.ErrRoleNotFound) {
+		t.Fatalf("expected ErrRoleNotFound, got %v", err)
+	}
+
+	if err := manager.RevokePrivileges(ctx, "admin", "user:read"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, _ := svc.HasPrivilege(ctx, "admin", "user:read"); ok {
+		t.Error("revoked privilege still cached")
+	}
+
+	if err := manager.CreateRole(ctx, rbac.Role{ID: "auditor"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.GrantPrivileges(ctx, "auditor", "user:read"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, err := svc.HasPrivilege(ctx, "auditor", "user:read"); err != nil || !ok {
+		t.Errorf("expected granted privilege, got %v, %v", ok, err)
+	}
+
+	if err := manager.DeleteRole(ctx, "auditor"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.GetRolePrivileges(ctx, "auditor"); !errors.Is(err, rbac.ErrRoleNotFound) {
+		t.Errorf("expected deleted role to be not found, got %v", err)
+	}
+}
+
+func TestGormRoleManager_ConcurrentRegistration(t *testing.T) {
+	manager := NewGormRoleManager(newTestDB(t), nil)
+	ctx := context.Background()
+
+	const callers = 8
+	errs := make(chan error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "user:delete"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	registered := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			registered++
+		case !errors.Is(err, rbac.ErrPrivilegeExists):
+			t.Errorf("expected ErrPrivilegeExists, got %v", err)
+		}
+	}
+	if registered != 1 {
+		t.Errorf("expected one registration to succeed, got %d", registered)
+	}
+}
+
+func TestGormRoleManager_Mapping(t *testing.T) {
+	manager, err := NewGormRoleManagerWithMapping(newLegacyDB(t), legacyMapping, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := manager.CreateRole(ctx, rbac.Role{ID: "auditor", Name: "Auditor"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.CreateRole(ctx, rbac.Role{ID: "retired"}); !errors.Is(err, rbac.ErrRoleExists) {
+		t.Errorf("expected the soft-deleted ID to be taken, got %v", err)
+	}
+	if err := manager.RenameRole(ctx, "auditor", "Reader"); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("expected ErrInvalidMapping without a name column, got %v", err)
+	}
+	if err := manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "user:purge"}); !errors.Is(err, rbac.ErrPrivilegeExists) {
+		t.Errorf("expected ErrPrivilegeExists, got %v", err)
+	}
+	if err := manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "report:read", Description: "Read reports"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.GrantPrivileges(ctx, "auditor", "report:read", "user:read"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.RevokePrivileges(ctx, "auditor", "user:read"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.DeleteRole(ctx, "empty"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	roles, total, err := manager.ListRoles(ctx, rbac.Page{})
+	wantRoles := []rbac.Role{{ID: "admin"}, {ID: "auditor"}}
+	if err != nil || total != 2 || !reflect.DeepEqual(roles, wantRoles) {
+		t.Errorf("expected %v, got %v of %d, %v", wantRoles, roles, total, err)
+	}
+
+	privileges, total, err := manager.ListPrivileges(ctx, rbac.Page{})
+	wantPrivileges := []rbac.Privilege{{Code: "report:read"}, {Code: "user:read"}, {Code: "user:write"}}
+	if err != nil || total != 3 || !reflect.DeepEqual(privileges, wantPrivileges) {
+		t.Errorf("expected %v, got %v of %d, %v", wantPrivileges, privileges, total, err)
+	}
+
+	for roleID, want := range map[string][]rbac.Privilege{
+		"admin":   {{Code: "user:read"}, {Code: "user:write"}},
+		"auditor": {{Code: "report:read"}},
+	} {
+		got, total, err := manager.ListRolePrivileges(ctx, roleID, rbac.Page{})
+		if err != nil || total != len(want) || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v of %d, %v", roleID, want, got, total, err)
+		}
+	}
+	if _, _, err := manager.ListRolePrivileges(ctx, "empty", rbac.Page{}); !errors.Is(err, rbac.ErrRoleNotFound) {
+		t.Errorf("expected the deleted role to be not found, got %v", err)
+	}
+}
+
+func TestNewGormRoleManagerWithMapping_NoRolesTable(t *testing.T) {
+	_, err := NewGormRoleManagerWithMapping(newTestDB(t), Mapping{}, nil)
+	if !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("expected ErrInvalidMapping, got %v", err)
+	}
+}
+
+func TestGormRoleManager_QueryTimeout(t *testing.T) {
+	manager := NewGormRoleManager(newTestDB(t), nil, WithQueryTimeout(time.Nanosecond))
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"create":   func() error { return manager.CreateRole(ctx, rbac.Role{ID: "auditor"}) },
+		"register": func() error { return manager.RegisterPrivilege(ctx, rbac.Privilege{Code: "user:delete"}) },
+		"list": func() error {
+			_, _, err := manager.ListRoles(ctx, rbac.Page{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected context.DeadlineExceeded, got %v", err)
+			}
+		})
+	}
+}
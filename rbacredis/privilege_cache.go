@@ -0,0 +1,206 @@
+package rbacredis
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hatmahat/go-rbac/rbac"
+)
+
+// DefaultKeyPrefix prefixes the keys of cached roles unless WithKeyPrefix says otherwise
+const DefaultKeyPrefix = "rbac:role:"
+
+// Client is the subset of a Redis client used by PrivilegeCache. Adapt go-redis,
+// rueidis or any other client (or a Redis-compatible server such as Valkey or
+// KeyDB) to it.
+type Client interface {
+	// Get returns the value of key; found is false when the key does not exist
+	Get(ctx context.Context, key string) (value string, found bool, err error)
+	// Set stores value under key, expiring after ttl; 0 means no expiry
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	// Del deletes keys, ignoring missing ones
+	Del(ctx context.Context, keys ...string) error
+	// Scan iterates the keys matching a glob pattern like SCAN; a next cursor of 0
+	// ends the iteration
+	Scan(ctx context.Context, cursor uint64, match string, count int64) (keys []string, next uint64, err error)
+}
+
+// PrivilegeCache is an rbac.PrivilegeCache stored in Redis, so many instances of the
+// service share one warm cache
+type PrivilegeCache struct {
+	client    Client
+	keyPrefix string
+	scanCount int64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+var _ rbac.PrivilegeCache = (*PrivilegeCache)(nil)
+
+// Option configures a PrivilegeCache
+type Option func(*PrivilegeCache)
+
+// WithKeyPrefix sets the prefix of the keys of cached roles. Use distinct prefixes
+// for services that must not share roles.
+func WithKeyPrefix(prefix string) Option {
+	return func(c *PrivilegeCache) {
+		c.keyPrefix = prefix
+	}
+}
+
+// NewPrivilegeCache creates a PrivilegeCache on top of client
+func NewPrivilegeCache(client Client, opts ...Option) *PrivilegeCache {
+	c := &PrivilegeCache{client: client, keyPrefix: DefaultKeyPrefix, scanCount: 100}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// storedEntry is the JSON form of a cached role
+type storedEntry struct {
+	Privileges []string            `json:"privileges"`
+	LoadedAt   time.Time           `json:"loadedAt"`
+	ExpiresAt  time.Time           `json:"expiresAt,omitzero"`
+	Origins    map[string][]string `json:"origins,omitempty"`
+}
+
+// GetEntry reads the entry of a role
+func (c *PrivilegeCache) GetEntry(ctx context.Context, roleID string) (rbac.PrivilegeCacheEntry, bool, error) {
+	value, found, err := c.client.Get(ctx, c.keyPrefix+roleID)
+	if err != nil {
+		c.errors.Add(1)
+		return rbac.PrivilegeCacheEntry{}, false, err
+	}
+	if !found {
+		c.misses.Add(1)
+		return rbac.PrivilegeCacheEntry{}, false, nil
+	}
+
+	var stored storedEntry
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		c.errors.Add(1)
+		return rbac.PrivilegeCacheEntry{}, false, err
+	}
+
+	c.hits.Add(1)
+	return rbac.PrivilegeCacheEntry{
+		Privileges: rbac.NewPrivilegeSet(stored.Privileges...),
+		LoadedAt:   stored.LoadedAt,
+		ExpiresAt:  stored.ExpiresAt,
+		Origins:    stored.Origins,
+	}, true, nil
+}
+
+// SetEntry writes the entry of a role, letting Redis expire it at entry.ExpiresAt
+func (c *PrivilegeCache) SetEntry(ctx context.Context, roleID string, entry rbac.PrivilegeCacheEntry) error {
+	var ttl time.Duration
+	if !entry.ExpiresAt.IsZero() {
+		ttl = time.Until(entry.ExpiresAt)
+		if ttl <= 0 {
+			return c.DeleteEntry(ctx, roleID)
+		}
+	}
+
+	stored := storedEntry{
+		Privileges: entry.Privileges.List(),
+		LoadedAt:   entry.LoadedAt,
+		ExpiresAt:  entry.ExpiresAt,
+		Origins:    entry.Origins,
+	}
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	if err := c.client.Set(ctx, c.keyPrefix+roleID, string(value), ttl); err != nil {
+		c.errors.Add(1)
+		return err
+	}
+	return nil
+}
+
+// DeleteEntry deletes the entry of a role
+func (c *PrivilegeCache) DeleteEntry(ctx context.Context, roleID string) error {
+	if err := c.client.Del(ctx, c.keyPrefix+roleID); err != nil {
+		c.errors.Add(1)
+		return err
+	}
+	return nil
+}
+
+// Clear deletes every role stored under the key prefix
+func (c *PrivilegeCache) Clear(ctx context.Context) error {
+	return c.scan(ctx, func(keys []string) error {
+		if len(keys) == 0 {
+			return nil
+		}
+		return c.client.Del(ctx, keys...)
+	})
+}
+
+// Keys returns the IDs of every role stored under the key prefix
+func (c *PrivilegeCache) Keys(ctx context.Context) ([]string, error) {
+	var roleIDs []string
+	err := c.scan(ctx, func(keys []string) error {
+		for _, key := range keys {
+			roleIDs = append(roleIDs, strings.TrimPrefix(key, c.keyPrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roleIDs, nil
+}
+
+// Stats returns the metrics of this instance. Entries is -1: counting them would
+// take a full scan of the shared keyspace.
+func (c *PrivilegeCache) Stats() rbac.CacheStats {
+	return rbac.CacheStats{
+		Entries: -1,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Errors:  c.errors.Load(),
+	}
+}
+
+// scan calls fn with every page of keys under the key prefix
+func (c *PrivilegeCache) scan(ctx context.Context, fn func(keys []string) error) error {
+	match := escapeGlob(c.keyPrefix) + "*"
+
+	var cursor uint64
+	for {
+		keys, next, err := c.client.Scan(ctx, cursor, match, c.scanCount)
+		if err != nil {
+			c.errors.Add(1)
+			return err
+		}
+		if err := fn(keys); err != nil {
+			c.errors.Add(1)
+			return err
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// escapeGlob escapes the characters special to Redis glob patterns
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
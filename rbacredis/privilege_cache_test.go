@@ -0,0 +1,251 @@
+package rbacredis
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatmahat/go-rbac/rbac"
+)
+
+// fakeRedis is an in-process stand-in for a Redis server
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	err     error
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: make(map[string]string), expires: make(map[string]time.Time)}
+}
+
+func (f *fakeRedis) Get(ctx context.Context, key string) (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return "", false, f.err
+	}
+	f.expireLocked(key)
+	value, found := f.values[key]
+	return value, found, nil
+}
+
+func (f *fakeRedis) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+	f.values[key] = value
+	delete(f.expires, key)
+	if ttl > 0 {
+		f.expires[key] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (f *fakeRedis) Del(ctx context.Context, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+	for _, key := range keys {
+		delete(f.values, key)
+		delete(f.expires, key)
+	}
+	return nil
+}
+
+// Scan returns every matching key in one page; only trailing-* patterns are supported
+func (f *fakeRedis) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, 0, f.err
+	}
+	prefix := strings.NewReplacer(`\*`, "*", `\?`, "?", `\[`, "[", `\]`, "]", `\\`, `\`).
+		Replace(strings.TrimSuffix(match, "*"))
+
+	var keys []string
+	for key := range f.values {
+		f.expireLocked(key)
+		if _, ok := f.values[key]; ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, 0, nil
+}
+
+func (f *fakeRedis) expireLocked(key string) {
+	if expiresAt, ok := f.expires[key]; ok && !time.Now().Before(expiresAt) {
+		delete(f.values, key)
+		delete(f.expires, key)
+	}
+}
+
+func TestPrivilegeCache_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cache := NewPrivilegeCache(newFakeRedis())
+
+	loadedAt := time.Now().Truncate(time.Millisecond)
+	entry := rbac.PrivilegeCacheEntry{
+		Privileges: rbac.NewPrivilegeSet("user:read", "user:write"),
+		LoadedAt:   loadedAt,
+		Origins:    map[string][]string{"user:read": {"viewer"}},
+	}
+	if err := cache.SetEntry(ctx, "editor", entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, ok, err := cache.GetEntry(ctx, "editor")
+	if err != nil || !ok {
+		t.Fatalf("expected entry, got %v, %v", ok, err)
+	}
+	if got.Privileges.Len() != 2 || !got.Privileges.Has("user:write") || !got.LoadedAt.Equal(loadedAt) ||
+		len(got.Origins["user:read"]) != 1 {
+		t.Errorf("entry did not round-trip: %+v", got)
+	}
+
+	if _, ok, _ := cache.GetEntry(ctx, "viewer"); ok {
+		t.Error("expected miss for an unknown role")
+	}
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != -1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPrivilegeCache_Expiry(t *testing.T) {
+	ctx := context.Background()
+	cache := NewPrivilegeCache(newFakeRedis())
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		wait      time.Duration
+		wantFound bool
+	}{
+		{name: "no expiry", wantFound: true},
+		{name: "not yet expired", expiresAt: time.Now().Add(time.Minute), wantFound: true},
+		{name: "expired in redis", expiresAt: time.Now().Add(5 * time.Millisecond), wait: 10 * time.Millisecond},
+		{name: "already expired", expiresAt: time.Now().Add(-time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := rbac.PrivilegeCacheEntry{Privileges: rbac.NewPrivilegeSet("user:read"), LoadedAt: time.Now(), ExpiresAt: tt.expiresAt}
+			if err := cache.SetEntry(ctx, tt.name, entry); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			time.Sleep(tt.wait)
+
+			_, found, err := cache.GetEntry(ctx, tt.name)
+			if err != nil || found != tt.wantFound {
+				t.Errorf("expected found %v, got %v, %v", tt.wantFound, found, err)
+			}
+		})
+	}
+}
+
+func TestPrivilegeCache_KeysAndClear(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	first := NewPrivilegeCache(redis, WithKeyPrefix("app1:"))
+	second := NewPrivilegeCache(redis, WithKeyPrefix("app2:"))
+
+	entry := rbac.PrivilegeCacheEntry{Privileges: rbac.NewPrivilegeSet("user:read"), LoadedAt: time.Now()}
+	for _, roleID := range []string{"viewer", "editor"} {
+		if err := first.SetEntry(ctx, roleID, entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := second.SetEntry(ctx, "admin", entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys, err := first.Keys(ctx)
+	sort.Strings(keys)
+	if err != nil || strings.Join(keys, ",") != "editor,viewer" {
+		t.Errorf("expected editor,viewer, got %v, %v", keys, err)
+	}
+
+	if err := first.Clear(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys, _ := first.Keys(ctx); len(keys) != 0 {
+		t.Errorf("expected no keys after Clear, got %v", keys)
+	}
+	if keys, _ := second.Keys(ctx); len(keys) != 1 {
+		t.Errorf("expected Clear to leave other prefixes alone, got %v", keys)
+	}
+}
+
+func TestPrivilegeCache_Errors(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	redis.err = errors.New("connection refused")
+	cache := NewPrivilegeCache(redis)
+
+	if _, _, err := cache.GetEntry(ctx, "viewer"); err == nil {
+		t.Error("expected GetEntry to fail")
+	}
+	if err := cache.SetEntry(ctx, "viewer", rbac.PrivilegeCacheEntry{}); err == nil {
+		t.Error("expected SetEntry to fail")
+	}
+	if got := cache.Stats().Errors; got != 2 {
+		t.Errorf("expected 2 errors, got %d", got)
+	}
+}
+
+type countingRepository struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (r *countingRepository) FetchPrivilegesByRoleID(ctx context.Context, roleID string) (map[string]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+	return map[string]bool{"user:read": true}, nil
+}
+
+func TestPrivilegeCache_SharedBetweenServices(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	repo := &countingRepository{}
+
+	first := rbac.NewRBACService(repo, 0, nil, rbac.WithPrivilegeCache(NewPrivilegeCache(redis)))
+	second := rbac.NewRBACService(repo, 0, nil, rbac.WithPrivilegeCache(NewPrivilegeCache(redis)))
+
+	for _, svc := range []rbac.RBACService{first, second} {
+		ok, err := svc.HasPrivilege(ctx, "viewer", "user:read")
+		if err != nil || !ok {
+			t.Fatalf("expected privilege, got %v, %v", ok, err)
+		}
+	}
+	if repo.calls != 1 {
+		t.Errorf("expected the second service to use the shared cache, got %d repository calls", repo.calls)
+	}
+
+	// A delete on one instance is seen by the other
+	if err := first.DeleteRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := second.GetRolePrivileges(ctx, "viewer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.calls != 2 {
+		t.Errorf("expected a reload after the delete, got %d repository calls", repo.calls)
+	}
+}